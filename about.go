@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+)
+
+// About is a compact summary of the space for status pages, chat bots and humans
+type About struct {
+	Space      string        `json:"space"`
+	URL        string        `json:"url,omitempty"`
+	Address    string        `json:"address,omitempty"`
+	Contact    *AboutContact `json:"contact,omitempty"`
+	Open       *bool         `json:"open"`
+	LastChange int64         `json:"lastchange,omitempty"`
+	Message    string        `json:"message,omitempty"`
+}
+
+// AboutContact holds the primary ways to reach the space
+type AboutContact struct {
+	Email    string `json:"email,omitempty"`
+	Phone    string `json:"phone,omitempty"`
+	ML       string `json:"ml,omitempty"`
+	Mastodon string `json:"mastodon,omitempty"`
+	Matrix   string `json:"matrix,omitempty"`
+}
+
+// handleAbout serves a summary of the space with its current open state
+func (s *Server) handleAbout(w http.ResponseWriter, r *http.Request) {
+	s.setCacheControl(w)
+	//the message can change without a state change, so the summary is only revalidated by its ETag
+	state := s.currentState(w, r)
+
+	about := About{
+		Space:      s.doc.Space,
+		URL:        s.doc.URL,
+		Open:       state.Open,
+		LastChange: state.LastChange,
+		Message:    state.Message,
+	}
+	if s.doc.Location != nil {
+		about.Address = s.doc.Location.Address
+	}
+	if contact := s.doc.Contact; contact != nil {
+		about.Contact = &AboutContact{
+			Email:    contact.Email,
+			Phone:    contact.Phone,
+			ML:       contact.ML,
+			Mastodon: contact.Mastodon,
+			Matrix:   contact.Matrix,
+		}
+	}
+	s.writeJSON(w, r, about)
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StateOverrideRequest is the request body of POST /admin/state
+type StateOverrideRequest struct {
+	Open     *bool  `json:"open"`
+	Message  string `json:"message,omitempty"`
+	Duration string `json:"duration,omitempty"` // optional, overrides the configured default duration
+}
+
+// StateOverrideResponse describes the active state override
+type StateOverrideResponse struct {
+	Open      bool   `json:"open"`
+	Message   string `json:"message,omitempty"`
+	Since     int64  `json:"since"`
+	ExpiresAt int64  `json:"expires_at,omitempty"`
+}
+
+// stateOverride is a manually set lab state that takes precedence over the fetched one
+type stateOverride struct {
+	mu        sync.RWMutex
+	active    bool
+	open      bool
+	message   string
+	since     time.Time
+	expiresAt time.Time // zero means until cleared
+	clock     Clock
+}
+
+// get returns the active override, ok is false if there is none or it has expired
+func (o *stateOverride) get() (open bool, message string, since time.Time, ok bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	if !o.active || (!o.expiresAt.IsZero() && o.clock.Now().After(o.expiresAt)) {
+		return false, "", time.Time{}, false
+	}
+	return o.open, o.message, o.since, true
+}
+
+func (o *stateOverride) set(open bool, message string, duration time.Duration) StateOverrideResponse {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.active = true
+	o.open = open
+	o.message = message
+	o.since = o.clock.Now()
+	o.expiresAt = time.Time{}
+	if duration > 0 {
+		o.expiresAt = o.since.Add(duration)
+	}
+
+	response := StateOverrideResponse{Open: o.open, Message: o.message, Since: o.since.Unix()}
+	if !o.expiresAt.IsZero() {
+		response.ExpiresAt = o.expiresAt.Unix()
+	}
+	return response
+}
+
+func (o *stateOverride) clear() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.active = false
+}
+
+// requireAdmin only lets requests through that carry the configured admin token as bearer token
+func (s *Server) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		//without a configured token the admin endpoints are disabled
+		if s.config.AdminToken == "" || !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.config.AdminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// handleAdminState sets (POST) or clears (DELETE) the manual state override
+func (s *Server) handleAdminState(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		var req StateOverrideRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Open == nil {
+			http.Error(w, "invalid request body: open is required", http.StatusBadRequest)
+			return
+		}
+		duration := s.config.StateOverrideDuration
+		if req.Duration != "" {
+			d, err := time.ParseDuration(req.Duration)
+			if err != nil || d < 0 {
+				http.Error(w, "invalid duration: "+req.Duration, http.StatusBadRequest)
+				return
+			}
+			duration = d
+		}
+
+		previous, _ := s.currentStateEvent()
+		response := s.stateOverride.set(*req.Open, req.Message, duration)
+		s.logger.Info("lab state overridden", "open", *req.Open, "message", req.Message, "duration", duration)
+		s.onOverrideChange(previous)
+		p, _ := json.Marshal(response)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(p)
+	case http.MethodDelete:
+		previous, _ := s.currentStateEvent()
+		s.stateOverride.clear()
+		s.logger.Info("lab state override cleared")
+		s.onOverrideChange(previous)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// onOverrideChange is called whenever the override is set or cleared, it publishes the effective state to the
+// streams and mqtt and notifies the webhooks if the open state changed from previous
+func (s *Server) onOverrideChange(previous StateEvent) {
+	event, _ := s.currentStateEvent()
+	s.stateChanges.publish(event)
+	if !equalState(previous.Open, event.Open) && len(s.config.WebhookURLs) > 0 {
+		s.sendWebhooks(stateTransition{Previous: previous.Open, Open: event.Open, At: s.clock.Now()})
+	}
+}
+
+// RefreshResponse is the response body of POST /admin/refresh
+type RefreshResponse struct {
+	State   *State   `json:"state"`
+	Sensors *Sensors `json:"sensors,omitempty"`
+	Error   string   `json:"error,omitempty"` // why the lab state couldn't be fetched, the last known state is returned then
+}
+
+// handleAdminRefresh fetches the lab state and the sensors right away instead of waiting for the caches to expire
+func (s *Server) handleAdminRefresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var response RefreshResponse
+	if _, err := s.refreshLabState(r.Context()); err != nil {
+		s.logger.Warn("error while refreshing the lab state", "error", err)
+		response.Error = err.Error()
+	}
+	s.expireSensorCaches()
+	response.State = s.currentState(w, r)
+	ctx, cancel := s.upstreamContext(r)
+	defer cancel()
+	response.Sensors = s.currentSensors(ctx, s.doc.Sensors)
+	s.logger.Info("caches refreshed", "open", formatState(response.State.Open))
+	p, _ := json.Marshal(response)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if response.Error != "" {
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	w.Write(p)
+}
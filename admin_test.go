@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestAdminRefresh(t *testing.T) {
+	var open atomic.Bool
+	var fetches atomic.Int32
+	sensorsURL, _ := newSensorSource(t, `[{"location": "Hauptraum", "value": 21.5}]`)
+	config := testConfig(t)
+	config.AdminToken = "s3cret"
+	config.TemperatureSensorsURL = sensorsURL
+	s, _ := newTestServer(t, config, StateFetchFunc(func(ctx context.Context) (LabState, error) {
+		fetches.Add(1)
+		return LabState{Open: Pointer(open.Load())}, nil
+	}))
+
+	if doc := decodeJSON[SpaceAPIv15](t, serve(s, "GET", "/v15", nil)); doc.State.Open == nil || *doc.State.Open {
+		t.Fatalf("state.open = %s, want closed", formatState(doc.State.Open))
+	}
+	//the state changed, but the cache still holds the old one within its ttl
+	open.Store(true)
+	if doc := decodeJSON[SpaceAPIv15](t, serve(s, "GET", "/v15", nil)); doc.State.Open == nil || *doc.State.Open {
+		t.Fatalf("state.open = %s, want the cached closed state", formatState(doc.State.Open))
+	}
+
+	w := serve(s, "POST", "/admin/refresh", http.Header{"Authorization": {"Bearer s3cret"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	response := decodeJSON[RefreshResponse](t, w)
+	if response.State == nil || response.State.Open == nil || !*response.State.Open {
+		t.Errorf("refreshed state = %+v, want open", response.State)
+	}
+	if response.Sensors == nil || len(response.Sensors.Temperature) != 1 {
+		t.Errorf("refreshed sensors = %+v, want the temperature reading", response.Sensors)
+	}
+	if got := fetches.Load(); got != 2 {
+		t.Errorf("state was fetched %d times, want once more for the refresh", got)
+	}
+	if doc := decodeJSON[SpaceAPIv15](t, serve(s, "GET", "/v15", nil)); doc.State.Open == nil || !*doc.State.Open {
+		t.Errorf("state.open after the refresh = %s, want open", formatState(doc.State.Open))
+	}
+}
+
+func TestAdminRefreshRequiresToken(t *testing.T) {
+	var fetches atomic.Int32
+	fetcher := StateFetchFunc(func(ctx context.Context) (LabState, error) {
+		fetches.Add(1)
+		return LabState{Open: Pointer(true)}, nil
+	})
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+	}{
+		{"no token configured", "", "Bearer "},
+		{"no authorization", "s3cret", ""},
+		{"wrong token", "s3cret", "Bearer guess"},
+		{"not a bearer token", "s3cret", "Basic s3cret"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := testConfig(t)
+			config.AdminToken = test.token
+			s, _ := newTestServer(t, config, fetcher)
+			w := serve(s, "POST", "/admin/refresh", http.Header{"Authorization": {test.authorization}})
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+			}
+			if !strings.HasPrefix(w.Header().Get("WWW-Authenticate"), "Bearer") {
+				t.Errorf("WWW-Authenticate = %q, want a bearer challenge", w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+	if got := fetches.Load(); got != 0 {
+		t.Errorf("state was fetched %d times by unauthorized requests, want none", got)
+	}
+}
+
+func TestDebugSources(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusInternalServerError)
+	}))
+	t.Cleanup(upstream.Close)
+	sensorsURL := strings.Replace(upstream.URL, "http://", "http://sensors:hunter2@", 1) + "/temperature"
+	config := testConfig(t)
+	config.AdminToken = "s3cret"
+	config.TemperatureSensorsURL = sensorsURL
+	s, _ := newTestServer(t, config, staticState(LabState{Open: Pointer(true)}))
+	serve(s, "GET", "/v15", nil)
+
+	if w := serve(s, "GET", "/debug/sources", nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("status without a token = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	w := serve(s, "GET", "/debug/sources", http.Header{"Authorization": {"Bearer s3cret"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if strings.Contains(w.Body.String(), "hunter2") {
+		t.Errorf("body = %s, want the password redacted", w.Body)
+	}
+	var temperature *SourceStatus
+	for _, status := range decodeJSON[[]SourceStatus](t, w) {
+		if status.Name == "temperature" {
+			temperature = &status
+		}
+	}
+	if temperature == nil || temperature.URL != redactURL(sensorsURL) || temperature.LastError == "" {
+		t.Errorf("temperature source = %+v, want the redacted url and the last error", temperature)
+	}
+}
+
+func TestAdminStateOverridePublished(t *testing.T) {
+	payloads := make(chan WebhookPayload, 10)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload WebhookPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		payloads <- payload
+	}))
+	t.Cleanup(hook.Close)
+	config := testConfig(t)
+	config.AdminToken = "s3cret"
+	config.WebhookURLs = []string{hook.URL}
+	s, _ := newTestServer(t, config, staticState(LabState{Open: Pointer(true), LastChange: Pointer[int64](1700000000)}))
+	s.refreshLabState(context.Background())
+	server := httptest.NewServer(s.Handler())
+	t.Cleanup(server.Close)
+
+	admin := func(method, body string) {
+		t.Helper()
+		r, _ := http.NewRequest(method, server.URL+"/admin/state", strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer s3cret")
+		resp, err := http.DefaultClient.Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	stream := func() (*bufio.Reader, func() error) {
+		t.Helper()
+		resp, err := http.Get(server.URL + "/events/state")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bufio.NewReader(resp.Body), resp.Body.Close
+	}
+	overridden := fmt.Sprintf("event: state\ndata: {\"open\":false,\"lastchange\":%d}\n", testNow.Unix())
+	fetched := "event: state\ndata: {\"open\":true,\"lastchange\":1700000000}\n"
+
+	events, closeEvents := stream()
+	defer closeEvents()
+	if event, err := readEvent(events); err != nil || event != fetched {
+		t.Fatalf("initial event = %q, %v, want the fetched state", event, err)
+	}
+
+	admin("POST", `{"open": false}`)
+	if event, err := readEvent(events); err != nil || event != overridden {
+		t.Errorf("event after the override = %q, %v, want the overridden state", event, err)
+	}
+	if payload := <-payloads; payload.Open {
+		t.Errorf("webhook after the override = %+v, want closed", payload)
+	}
+	//new clients start with the overridden state
+	later, closeLater := stream()
+	if event, err := readEvent(later); err != nil || event != overridden {
+		t.Errorf("initial event while overridden = %q, %v, want the overridden state", event, err)
+	}
+	closeLater()
+
+	admin("DELETE", "")
+	if event, err := readEvent(events); err != nil || event != fetched {
+		t.Errorf("event after clearing the override = %q, %v, want the fetched state", event, err)
+	}
+	if payload := <-payloads; !payload.Open {
+		t.Errorf("webhook after clearing the override = %+v, want open", payload)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"text/template"
+	"unicode/utf8"
+)
+
+// badgeTemplate renders a flat shields.io style badge, all values are escaped by the caller
+var badgeTemplate = template.Must(template.New("badge").Parse(`<svg xmlns="http://www.w3.org/2000/svg" width="{{.Width}}" height="20" role="img" aria-label="{{.Label}}: {{.Status}}">
+<title>{{.Label}}: {{.Status}}</title>
+<linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>
+<clipPath id="r"><rect width="{{.Width}}" height="20" rx="3" fill="#fff"/></clipPath>
+<g clip-path="url(#r)">
+<rect width="{{.LabelWidth}}" height="20" fill="#555"/>
+<rect x="{{.LabelWidth}}" width="{{.StatusWidth}}" height="20" fill="{{.Color}}"/>
+<rect width="{{.Width}}" height="20" fill="url(#s)"/>
+</g>
+<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+<text x="{{.LabelX}}" y="14">{{.Label}}</text>
+<text x="{{.StatusX}}" y="14">{{.Status}}</text>
+</g>
+</svg>
+`))
+
+// badge holds the computed layout of a status badge
+type badge struct {
+	Label, Status, Color           string
+	Width, LabelWidth, StatusWidth int
+	LabelX, StatusX                float64
+}
+
+// newBadge lays out a badge, the text width is estimated as the font is not available server-side
+func newBadge(label, status, color string) badge {
+	labelWidth := textWidth(label)
+	statusWidth := textWidth(status)
+	return badge{
+		Label:       template.HTMLEscapeString(label),
+		Status:      template.HTMLEscapeString(status),
+		Color:       color,
+		Width:       labelWidth + statusWidth,
+		LabelWidth:  labelWidth,
+		StatusWidth: statusWidth,
+		LabelX:      float64(labelWidth) / 2,
+		StatusX:     float64(labelWidth) + float64(statusWidth)/2,
+	}
+}
+
+// textWidth estimates the rendered width of s in pixels including padding
+func textWidth(s string) int {
+	return utf8.RuneCountInString(s)*7 + 10
+}
+
+// handleBadge serves an SVG badge showing whether the space is open, closed or in an unknown state
+func (s *Server) handleBadge(w http.ResponseWriter, r *http.Request) {
+	state := s.currentState(w, r)
+	status, color := "unknown", "#9f9f9f"
+	if state.Open != nil && *state.Open {
+		status, color = "open", "#4c1"
+	} else if state.Open != nil {
+		status, color = "closed", "#e05d44"
+	}
+
+	var body bytes.Buffer
+	if err := badgeTemplate.Execute(&body, newBadge(s.doc.Space, status, color)); err != nil {
+		s.logger.Error("error while rendering badge", "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	s.setCacheControl(w)
+	if checkNotModified(w, r, body.Bytes()) {
+		return
+	}
+	w.Write(body.Bytes())
+}
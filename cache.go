@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// stateCache holds the last successfully fetched lab state for a limited time
+type stateCache struct {
+	mu        sync.RWMutex
+	ttl       time.Duration
+	state     LabState
+	fetchedAt time.Time
+	lastErr   error
+	clock     Clock
+}
+
+func newStateCache(ttl time.Duration, clock Clock) *stateCache {
+	return &stateCache{ttl: ttl, clock: clock}
+}
+
+// last returns the last successfully fetched lab state regardless of its age, ok is false if there never was one
+func (c *stateCache) last() (state LabState, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.fetchedAt.IsZero() {
+		return LabState{}, false
+	}
+	return c.state, true
+}
+
+// lookup returns the last successfully fetched lab state regardless of its age and whether it was fetched within
+// the ttl, ok is false if there never was one, only a fresh state counts as a cache hit, the fetches are counted
+// as misses by refreshLabState
+func (c *stateCache) lookup() (state LabState, ok, fresh bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.fetchedAt.IsZero() {
+		return LabState{}, false, false
+	}
+	fresh = c.clock.Now().Sub(c.fetchedAt) <= c.ttl
+	if fresh {
+		cacheHitsTotal.WithLabelValues("state").Inc()
+	}
+	return c.state, true, fresh
+}
+
+// stateTransition describes a detected change of the open state
+type stateTransition struct {
+	Previous   *bool // the previous open state, only meaningful if Initial is false
+	Initial    bool  // true if no state was known before
+	Open       *bool
+	LastChange *int64
+	At         time.Time
+}
+
+// set stores a freshly fetched lab state, changed is true if the open state differs from the previous one,
+// if the state api doesn't report when the state last changed, the time the change was detected is used instead
+func (c *stateCache) set(state LabState) (stored LabState, transition stateTransition, changed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock.Now()
+	changed = c.fetchedAt.IsZero() || !equalState(c.state.Open, state.Open)
+	if state.LastChange == nil {
+		if changed {
+			state.LastChange = Pointer(now.Unix())
+		} else {
+			state.LastChange = c.state.LastChange
+		}
+	}
+	transition = stateTransition{
+		Previous:   c.state.Open,
+		Initial:    c.fetchedAt.IsZero(),
+		Open:       state.Open,
+		LastChange: state.LastChange,
+		At:         now,
+	}
+
+	c.state = state
+	c.fetchedAt = now
+	c.lastErr = nil
+	return state, transition, changed
+}
+
+// restore seeds the cache with a previously fetched lab state, e.g. one persisted across restarts
+func (c *stateCache) restore(state LabState, fetchedAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.state = state
+	c.fetchedAt = fetchedAt
+}
+
+// fail records that the last fetch of the lab state failed
+func (c *stateCache) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lastErr = err
+}
+
+// status returns the error of the last fetch (nil if it succeeded) and the time of the last successful fetch
+func (c *stateCache) status() (lastErr error, lastSuccess time.Time) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.lastErr, c.fetchedAt
+}
+
+// refreshLabState fetches the lab state from the state api, bypassing the cache, and updates the cache,
+// concurrent refreshes share a single fetch
+func (s *Server) refreshLabState(ctx context.Context) (LabState, error) {
+	cacheMissesTotal.WithLabelValues("state").Inc()
+	//the fetch is shared, so one caller going away must not cancel it for the others
+	result := s.stateFetches.DoChan("state", func() (any, error) {
+		return s.fetchAndStoreLabState(context.WithoutCancel(ctx))
+	})
+	select {
+	case result := <-result:
+		if result.Err != nil {
+			return LabState{}, result.Err
+		}
+		return result.Val.(LabState), nil
+	case <-ctx.Done():
+		return LabState{}, ctx.Err()
+	}
+}
+
+// fetchAndStoreLabState fetches the lab state and updates the cache, the history and all subscribers
+func (s *Server) fetchAndStoreLabState(ctx context.Context) (LabState, error) {
+	start := time.Now()
+	state, err := s.stateFetcher.Fetch(ctx)
+	observeStateFetch(start, err)
+	if err != nil {
+		s.stateCache.fail(err)
+		return LabState{}, err
+	}
+	state, transition, changed := s.stateCache.set(state)
+	if changed {
+		s.logger.Info("lab state changed", "open", formatState(state.Open))
+		s.onStateChange(transition)
+	}
+	setOpenGauge(state.Open)
+	if s.config.StateFile != "" {
+		if err := saveState(s.config.StateFile, state, s.clock.Now()); err != nil {
+			s.logger.Warn("error while persisting lab state", "path", s.config.StateFile, "error", err)
+		}
+	}
+	return state, nil
+}
+
+// jitter returns a random duration below the configured cache ttl jitter, which is added to ttls and refresh
+// intervals so that instances and caches don't all refresh at the same instant
+func (s *Server) jitter() time.Duration {
+	if s.config.CacheTTLJitter <= 0 {
+		return 0
+	}
+	return s.random(s.config.CacheTTLJitter)
+}
+
+// onStateChange is called whenever a fetch detects that the open state changed
+func (s *Server) onStateChange(transition stateTransition) {
+	s.recordTransition(transition)
+	//while a manual override is active the published state doesn't change
+	if _, _, _, overridden := s.stateOverride.get(); overridden {
+		return
+	}
+	s.stateChanges.publish(newStateEvent(transition.Open, transition.LastChange))
+	//the first state after startup is not a transition downstream automations should act on
+	if !transition.Initial && len(s.config.WebhookURLs) > 0 {
+		s.sendWebhooks(transition)
+	}
+}
+
+// equalState reports whether two (possibly unknown) open states are the same
+func equalState(a, b *bool) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// formatState returns a human readable representation of an open state
+func formatState(open *bool) string {
+	if open == nil {
+		return "unknown"
+	}
+	if *open {
+		return "open"
+	}
+	return "closed"
+}
+
+// cacheSchedules lists the schedules allowed by the SpaceAPI cache field, shortest first
+var cacheSchedules = []struct {
+	schedule string
+	interval time.Duration
+}{
+	{"m.02", 2 * time.Minute},
+	{"m.05", 5 * time.Minute},
+	{"m.10", 10 * time.Minute},
+	{"m.15", 15 * time.Minute},
+	{"m.30", 30 * time.Minute},
+	{"h.01", time.Hour},
+	{"h.02", 2 * time.Hour},
+	{"h.04", 4 * time.Hour},
+	{"h.08", 8 * time.Hour},
+	{"h.12", 12 * time.Hour},
+	{"d.01", 24 * time.Hour},
+}
+
+// cacheSchedule returns the shortest SpaceAPI cache schedule that is not shorter than ttl
+func cacheSchedule(ttl time.Duration) string {
+	for _, s := range cacheSchedules {
+		if ttl <= s.interval {
+			return s.schedule
+		}
+	}
+	return cacheSchedules[len(cacheSchedules)-1].schedule
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStateCacheFresh(t *testing.T) {
+	clock := newFakeClock(time.Unix(1700000000, 0))
+	cache := newStateCache(time.Minute, clock)
+	if _, ok, fresh := cache.lookup(); ok || fresh {
+		t.Fatalf("lookup() on an empty cache = %v, %v, want no state", ok, fresh)
+	}
+
+	cache.set(LabState{Open: Pointer(true)})
+	clock.Advance(time.Minute)
+	if _, ok, fresh := cache.lookup(); !ok || !fresh {
+		t.Errorf("lookup() at the end of the ttl = %v, %v, want a fresh state", ok, fresh)
+	}
+	clock.Advance(time.Second)
+	if state, ok, fresh := cache.lookup(); !ok || fresh || !*state.Open {
+		t.Errorf("lookup() after the ttl = %+v, %v, %v, want the expired state", state, ok, fresh)
+	}
+}
+
+func TestStateCacheSetLastChange(t *testing.T) {
+	clock := newFakeClock(time.Unix(1700000000, 0))
+	cache := newStateCache(time.Minute, clock)
+
+	stored, transition, changed := cache.set(LabState{Open: Pointer(true)})
+	if !changed || !transition.Initial {
+		t.Errorf("first state: changed = %v, initial = %v, want both true", changed, transition.Initial)
+	}
+	if *stored.LastChange != 1700000000 {
+		t.Errorf("first state: lastchange = %d, want the time it was detected", *stored.LastChange)
+	}
+
+	//the state api doesn't report a last change, so it stays at the time the state was first seen
+	clock.Advance(time.Hour)
+	stored, _, changed = cache.set(LabState{Open: Pointer(true)})
+	if changed || *stored.LastChange != 1700000000 {
+		t.Errorf("same state: changed = %v, lastchange = %d, want false, 1700000000", changed, *stored.LastChange)
+	}
+
+	clock.Advance(time.Hour)
+	stored, transition, changed = cache.set(LabState{Open: Pointer(false)})
+	if !changed || transition.Initial || !*transition.Previous {
+		t.Errorf("changed state: changed = %v, transition = %+v", changed, transition)
+	}
+	if *stored.LastChange != 1700000000+2*3600 {
+		t.Errorf("changed state: lastchange = %d, want the time the change was detected", *stored.LastChange)
+	}
+}
+
+func TestConcurrentRequestsShareFetch(t *testing.T) {
+	var requests atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		//long enough for all requests to arrive while the first fetch is still running
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte(`{"status":"open"}`))
+	}))
+	defer upstream.Close()
+
+	config := testConfig(t)
+	s, _ := newTestServer(t, config, newTestHTTPFetcher(config, upstream.URL))
+	const concurrency = 20
+	var wg sync.WaitGroup
+	codes := make([]int, concurrency)
+	for i := range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes[i] = serve(s, "GET", "/state", nil).Code
+		}()
+	}
+	wg.Wait()
+
+	for i, code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("request %d: status = %d, want %d", i, code, http.StatusOK)
+		}
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("state api was requested %d times by %d concurrent requests, want once", got, concurrency)
+	}
+}
+
+func TestRefreshLabStateCallerCancel(t *testing.T) {
+	release := make(chan struct{})
+	s, _ := newTestServer(t, testConfig(t), StateFetchFunc(func(ctx context.Context) (LabState, error) {
+		<-release
+		return LabState{Open: Pointer(true)}, ctx.Err()
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.refreshLabState(ctx); err == nil {
+		t.Error("refreshLabState() with a cancelled context succeeded, want an error")
+	}
+	//the shared fetch goes on without the caller and stores its result for everyone else
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for {
+		if state, ok := s.stateCache.last(); ok {
+			if !equalState(state.Open, Pointer(true)) {
+				t.Errorf("cached state = %s, want open", formatState(state.Open))
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the fetch of the cancelled caller was never stored")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestJitterWindow(t *testing.T) {
+	config := testConfig(t)
+	config.CacheTTLJitter = 30 * time.Second
+	s, _ := newTestServer(t, config, staticState(LabState{}), WithRandom(rand.N[time.Duration]))
+	for range 1000 {
+		if jitter := s.jitter(); jitter < 0 || jitter >= config.CacheTTLJitter {
+			t.Fatalf("jitter() = %s, want it within [0, %s)", jitter, config.CacheTTLJitter)
+		}
+	}
+
+	//the effective ttl of the caches includes the jitter drawn from the injected source
+	s, clock := newTestServer(t, config, staticState(LabState{}), WithRandom(func(n time.Duration) time.Duration {
+		if n != config.CacheTTLJitter {
+			t.Errorf("random(%s) called, want the configured jitter %s", n, config.CacheTTLJitter)
+		}
+		return 20 * time.Second
+	}))
+	fetches := 0
+	fetch := func() ([]string, error) {
+		fetches++
+		return nil, nil
+	}
+	s.gitHubCache.getOrFetch(context.Background(), fetch)
+	clock.Advance(config.GitHubRefresh + 20*time.Second)
+	s.gitHubCache.getOrFetch(context.Background(), fetch)
+	if fetches != 1 {
+		t.Errorf("fetched %d times within the ttl plus jitter, want once", fetches)
+	}
+	clock.Advance(time.Second)
+	s.gitHubCache.getOrFetch(context.Background(), fetch)
+	if fetches != 2 {
+		t.Errorf("fetched %d times after the ttl plus jitter, want twice", fetches)
+	}
+
+	config.CacheTTLJitter = 0
+	s, _ = newTestServer(t, config, staticState(LabState{}), WithRandom(func(n time.Duration) time.Duration {
+		t.Errorf("random(%s) called without a configured jitter", n)
+		return n
+	}))
+	if jitter := s.jitter(); jitter != 0 {
+		t.Errorf("jitter() = %s without a configured jitter, want 0", jitter)
+	}
+}
@@ -0,0 +1,15 @@
+package main
+
+import "time"
+
+// Clock tells the current time, everything that depends on it asks the server's clock instead of calling time.Now
+type Clock interface {
+	Now() time.Time
+}
+
+// realClock is the wall clock
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
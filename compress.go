@@ -0,0 +1,115 @@
+package main
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// gzipResponseWriter compresses the response body if the response is eligible for compression
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	request     *http.Request
+	gz          *gzip.Writer
+	encoded     bool // the response is announced as gzip encoded, also for HEAD requests which have no body
+	wroteHeader bool
+}
+
+func (g *gzipResponseWriter) WriteHeader(status int) {
+	if g.wroteHeader {
+		return
+	}
+	g.wroteHeader = true
+
+	h := g.Header()
+	h.Add("Vary", "Accept-Encoding")
+
+	//responses without a body and already encoded responses are passed through untouched
+	if status != http.StatusNotModified && status != http.StatusNoContent && h.Get("Content-Encoding") == "" {
+		g.encoded = true
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		//the compressed representation differs byte-wise, so the ETag can only be weak
+		if etag := h.Get("ETag"); strings.HasPrefix(etag, `"`) {
+			h.Set("ETag", "W/"+etag)
+		}
+		//a HEAD response carries the headers of the GET response, but there is nothing to compress
+		if g.request.Method != http.MethodHead {
+			g.gz = gzip.NewWriter(g.ResponseWriter)
+		}
+	}
+	g.ResponseWriter.WriteHeader(status)
+}
+
+func (g *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !g.wroteHeader {
+		g.WriteHeader(http.StatusOK)
+	}
+	if g.gz != nil {
+		return g.gz.Write(b)
+	}
+	//net/http would announce the length of the uncompressed body as Content-Length of a HEAD response
+	if g.encoded {
+		return len(b), nil
+	}
+	return g.ResponseWriter.Write(b)
+}
+
+// Flush flushes the compressed data written so far to the client
+func (g *gzipResponseWriter) Flush() {
+	if g.gz != nil {
+		g.gz.Flush()
+	}
+	http.NewResponseController(g.ResponseWriter).Flush()
+}
+
+// Unwrap allows http.ResponseController to reach the underlying ResponseWriter
+func (g *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return g.ResponseWriter
+}
+
+func (g *gzipResponseWriter) close() error {
+	if g.gz != nil {
+		return g.gz.Close()
+	}
+	return nil
+}
+
+// acceptsGzip reports whether the Accept-Encoding header allows a gzip encoded response
+func acceptsGzip(acceptEncoding string) bool {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		//a quality value of zero explicitly refuses the encoding
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// compress gzips responses for clients that advertise gzip support
+func compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		//upgraded connections like websockets are not http responses and can't be compressed here
+		if r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		gw := &gzipResponseWriter{ResponseWriter: w, request: r}
+		defer gw.close()
+		next.ServeHTTP(gw, r)
+	})
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		acceptEncoding string
+		want           bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"deflate, gzip;q=0.8, br", true},
+		{"GZIP", true},
+		{"gzip;q=0", false},
+		{"br, deflate", false},
+	}
+	for _, test := range tests {
+		if got := acceptsGzip(test.acceptEncoding); got != test.want {
+			t.Errorf("acceptsGzip(%q) = %v, want %v", test.acceptEncoding, got, test.want)
+		}
+	}
+}
+
+// request sends a request with the given Accept-Encoding to server, the response is not decompressed
+func request(t *testing.T, server *httptest.Server, method, path, acceptEncoding string) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(method, server.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, body
+}
+
+func TestHeadGzip(t *testing.T) {
+	s, _ := newTestServer(t, testConfig(t), staticState(LabState{Open: Pointer(true), LastChange: Pointer[int64](1700000000)}))
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+
+	for _, path := range []string{"/v14", "/v15", "/state"} {
+		get, compressed := request(t, server, "GET", path, "gzip")
+		head, body := request(t, server, "HEAD", path, "gzip")
+		if head.StatusCode != http.StatusOK {
+			t.Fatalf("HEAD %s: status = %d, want %d", path, head.StatusCode, http.StatusOK)
+		}
+		if len(body) != 0 {
+			t.Errorf("HEAD %s: body = %q, want none", path, body)
+		}
+		for _, key := range []string{"Content-Type", "Content-Encoding", "ETag", "Last-Modified", "Vary", "Cache-Control"} {
+			if head.Header.Get(key) == "" || head.Header.Get(key) != get.Header.Get(key) {
+				t.Errorf("HEAD %s: %s = %q, want %q as for GET", path, key, head.Header.Get(key), get.Header.Get(key))
+			}
+		}
+		if head.Header.Get("Content-Encoding") != "gzip" {
+			t.Errorf("HEAD %s: Content-Encoding = %q, want gzip", path, head.Header.Get("Content-Encoding"))
+		}
+		//the length of the uncompressed body would be wrong for the compressed GET response
+		if head.ContentLength > 0 {
+			t.Errorf("HEAD %s: Content-Length = %d, want none", path, head.ContentLength)
+		}
+
+		gz, err := gzip.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		var doc map[string]any
+		if err := json.NewDecoder(gz).Decode(&doc); err != nil {
+			t.Errorf("GET %s: invalid compressed JSON: %v", path, err)
+		}
+	}
+}
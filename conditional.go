@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// computeETag returns a strong ETag for the given response body
+func computeETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches reports whether the If-None-Match header matches etag, using weak comparison
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// setCacheControl allows clients and intermediaries to cache the response for the configured max age
+func (s *Server) setCacheControl(w http.ResponseWriter) {
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(s.config.CacheMaxAge.Seconds())))
+}
+
+// checkNotModified sets the ETag header and answers with 304 if the client already has this version,
+// it returns true if the response has been written
+func checkNotModified(w http.ResponseWriter, r *http.Request, body []byte) bool {
+	etag := computeETag(body)
+	w.Header().Set("ETag", etag)
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" && etagMatches(inm, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// setLastModified sets the Last-Modified header to the last state change, if it is known, and returns the time it was
+// set to, conditional requests are left to the caller
+func setLastModified(w http.ResponseWriter, lastChange int64) (time.Time, bool) {
+	if lastChange <= 0 {
+		return time.Time{}, false
+	}
+	modified := time.Unix(lastChange, 0).UTC()
+	w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+	return modified, true
+}
+
+// checkNotModifiedSince sets the Last-Modified header to the last state change and answers with 304 if the client
+// already has this version, If-None-Match takes precedence if present, it returns true if the response has been written,
+// it must only be used for responses that change with the state and nothing else
+func checkNotModifiedSince(w http.ResponseWriter, r *http.Request, lastChange int64) bool {
+	modified, ok := setLastModified(w, lastChange)
+	if !ok || r.Header.Get("If-None-Match") != "" {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || modified.After(since) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
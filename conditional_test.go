@@ -0,0 +1,135 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEtagMatches(t *testing.T) {
+	etag := computeETag([]byte(`{"open":true}`))
+	tests := []struct {
+		ifNoneMatch string
+		want        bool
+	}{
+		{etag, true},
+		{"W/" + etag, true},
+		{`"other", ` + etag, true},
+		{"*", true},
+		{`"other"`, false},
+		{computeETag([]byte(`{"open":false}`)), false},
+	}
+	for _, test := range tests {
+		if got := etagMatches(test.ifNoneMatch, etag); got != test.want {
+			t.Errorf("etagMatches(%q) = %v, want %v", test.ifNoneMatch, got, test.want)
+		}
+	}
+}
+
+func TestCheckNotModified(t *testing.T) {
+	body := []byte(`{"open":true}`)
+	etag := computeETag(body)
+	tests := []struct {
+		ifNoneMatch string
+		want        int
+	}{
+		{"", http.StatusOK},
+		{etag, http.StatusNotModified},
+		{`"outdated"`, http.StatusOK},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/v15", nil)
+		if test.ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", test.ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		if !checkNotModified(w, r, body) {
+			w.Write(body)
+		}
+		if w.Code != test.want {
+			t.Errorf("If-None-Match %q: status = %d, want %d", test.ifNoneMatch, w.Code, test.want)
+		}
+		if w.Header().Get("ETag") != etag {
+			t.Errorf("If-None-Match %q: ETag = %q, want %q", test.ifNoneMatch, w.Header().Get("ETag"), etag)
+		}
+	}
+}
+
+func TestCheckNotModifiedSince(t *testing.T) {
+	const lastChange = 1700000000 //Tue, 14 Nov 2023 22:13:20 GMT
+	tests := []struct {
+		name   string
+		header http.Header
+		want   int
+	}{
+		{"no conditional headers", nil, http.StatusOK},
+		{"at the last change", http.Header{"If-Modified-Since": {"Tue, 14 Nov 2023 22:13:20 GMT"}}, http.StatusNotModified},
+		{"after the last change", http.Header{"If-Modified-Since": {"Wed, 15 Nov 2023 08:00:00 GMT"}}, http.StatusNotModified},
+		{"before the last change", http.Header{"If-Modified-Since": {"Tue, 14 Nov 2023 22:13:19 GMT"}}, http.StatusOK},
+		{"invalid date", http.Header{"If-Modified-Since": {"yesterday"}}, http.StatusOK},
+		{"If-None-Match takes precedence", http.Header{
+			"If-Modified-Since": {"Wed, 15 Nov 2023 08:00:00 GMT"},
+			"If-None-Match":     {`"outdated"`},
+		}, http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/state", nil)
+			for key, values := range test.header {
+				r.Header[key] = values
+			}
+			w := httptest.NewRecorder()
+			if !checkNotModifiedSince(w, r, lastChange) {
+				w.WriteHeader(http.StatusOK)
+			}
+			if w.Code != test.want {
+				t.Errorf("status = %d, want %d", w.Code, test.want)
+			}
+			if modified := w.Header().Get("Last-Modified"); modified != "Tue, 14 Nov 2023 22:13:20 GMT" {
+				t.Errorf("Last-Modified = %q, want the last change", modified)
+			}
+		})
+	}
+}
+
+func TestCheckNotModifiedSinceUnknownLastChange(t *testing.T) {
+	r := httptest.NewRequest("GET", "/state", nil)
+	r.Header.Set("If-Modified-Since", "Wed, 15 Nov 2023 08:00:00 GMT")
+	w := httptest.NewRecorder()
+	if checkNotModifiedSince(w, r, 0) {
+		t.Error("checkNotModifiedSince() answered with 304 without a known last change")
+	}
+	if modified := w.Header().Get("Last-Modified"); modified != "" {
+		t.Errorf("Last-Modified = %q, want none", modified)
+	}
+}
+
+func TestSpaceAPILastModified(t *testing.T) {
+	s, _ := newTestServer(t, testConfig(t), staticState(LabState{Open: Pointer(true), LastChange: Pointer[int64](1700000000)}))
+	for _, target := range []string{"/v13", "/v14", "/v15"} {
+		//the document also changes with sensors, events and projects, so only its ETag revalidates it
+		w := serve(s, "GET", target, http.Header{"If-Modified-Since": {"Wed, 15 Nov 2023 08:00:00 GMT"}})
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: status = %d, want %d", target, w.Code, http.StatusOK)
+		}
+		if modified := w.Header().Get("Last-Modified"); modified != "Tue, 14 Nov 2023 22:13:20 GMT" {
+			t.Errorf("%s: Last-Modified = %q, want the last state change", target, modified)
+		}
+		w = serve(s, "GET", target, http.Header{"If-None-Match": {w.Header().Get("ETag")}})
+		if w.Code != http.StatusNotModified {
+			t.Errorf("%s: status with the ETag = %d, want %d", target, w.Code, http.StatusNotModified)
+		}
+	}
+}
+
+func TestAboutNoLastModified(t *testing.T) {
+	s, _ := newTestServer(t, testConfig(t), staticState(LabState{Open: Pointer(true), LastChange: Pointer[int64](1700000000)}))
+	//the message can change without a state change
+	w := serve(s, "GET", "/about", http.Header{"If-Modified-Since": {"Wed, 15 Nov 2023 08:00:00 GMT"}})
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if modified := w.Header().Get("Last-Modified"); modified != "" {
+		t.Errorf("Last-Modified = %q, want none", modified)
+	}
+}
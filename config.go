@@ -0,0 +1,608 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds the runtime configuration of the server
+type Config struct {
+	StateAPIURLs      []string      // URLs of the upstream lab state APIs, in order of priority
+	StateFetchTimeout time.Duration // timeout for a single request to the upstream lab state API
+	StateCacheTTL     time.Duration // how long a fetched lab state is served from cache
+	CacheTTLJitter    time.Duration // maximum random time added to cache ttls and refresh intervals to spread out refreshes
+	CacheMaxAge       time.Duration // how long clients and intermediaries may cache the SpaceAPI responses
+	ListenAddr        string        // address the http server listens on, host:port or unix:/path/to.sock
+	ListenSocketMode  os.FileMode   // permissions of the unix socket the http server listens on
+	TLSCertFile       string        // optional certificate file, serves HTTPS together with TLSKeyFile
+	TLSKeyFile        string        // optional private key file, serves HTTPS together with TLSCertFile
+	ShutdownTimeout   time.Duration // grace period for in-flight requests on shutdown
+	LogLevel          slog.Level    // minimum level of log messages (debug, info, warn, error)
+	LogFormat         string        // format of log messages (text, json)
+	LogRequests       bool          // whether every http request is logged
+	ConfigFile        string        // optional JSON/YAML file holding the static space document
+	StateFile         string        // optional file the last known lab state is persisted to
+	AllowedOrigins    []string      // origins allowed to access the api via CORS, empty or "*" allows all
+	StrictValidation  bool          // whether schema violations of the space document prevent startup
+	StreamHeartbeat   time.Duration // interval of heartbeats on idle streaming connections
+	HistorySize       int           // number of state transitions kept in the history
+	HistoryFile       string        // optional file the state history is persisted to
+
+	WebhookURLs     []string      // URLs notified with a POST when the open state changes
+	WebhookTimeout  time.Duration // timeout of a single webhook request
+	WebhookAttempts int           // maximum number of requests per webhook and state change
+
+	StateFetchAttempts    int           // maximum number of requests per state fetch
+	StateFetchRetryDelay  time.Duration // base delay before the first retry, doubled for every further retry
+	StateFetchRetryJitter time.Duration // maximum random delay added to every retry
+	StateFetchDeadline    time.Duration // overall deadline of a state fetch including all retries
+	StateRefreshInterval  time.Duration // interval the lab state is refreshed in the background
+	StateStatusPath       string        // json pointer to the status in the response of the state api
+	StateOpenTokens       []string      // status values of the state api meaning open, matched ignoring case
+	StateClosedTokens     []string      // status values of the state api meaning closed, matched ignoring case
+	StateMergeStrategy    string        // how the states of multiple state apis are combined (first, or, and)
+
+	AdminToken            string        // bearer token protecting the admin endpoints, empty disables them
+	StateOverrideDuration time.Duration // default duration of a manual state override, zero means until cleared
+
+	TemperatureSensorsURL string        // optional source of temperature readings
+	TemperatureUnit       string        // unit all temperature readings are converted to (°C, °F, K)
+	CO2SensorsURL         string        // optional source of CO2 readings
+	CO2MaxAge             time.Duration // CO2 readings older than this are dropped
+	DoorLockURL           string        // optional source of the front door lock state
+	BeverageSupplyURL     string        // optional source of the beverage stock
+	HumiditySensorsURL    string        // optional source of relative humidity readings
+	BarometerSensorsURL   string        // optional source of barometric pressure readings
+	RadiationSensorsURL   string        // optional source of radiation readings
+	RadiationTypes        []string      // radiation types the source provides (alpha, beta, gamma, beta_gamma)
+	SensorMaxStale        time.Duration // how long the last readings of a failing sensor source are still served
+
+	EventsCalendarURL string        // optional iCalendar feed the upcoming events are read from
+	EventsLookahead   time.Duration // how far into the future events are included
+	EventsMax         int           // maximum number of events included
+	MastodonAccount   string        // optional account whose posts tagged MastodonTag are included as events, e.g. @metalab@chaos.social
+	MastodonTag       string        // hashtag (without #) marking posts as events
+	MastodonRefresh   time.Duration // how long fetched posts are served from cache
+
+	ExposeTriggerPerson bool   // whether the person who last opened or closed the space is published
+	StateIconOpen       string // URL of the icon shown while the space is open
+	StateIconClosed     string // URL of the icon shown while the space is closed
+
+	CamURLs        []string // URLs of the public webcams of the space
+	RadioShowRRule string   // optional daily or weekly recurrence rule of the radio show, e.g. FREQ=WEEKLY;BYDAY=FR
+
+	KeymastersURL         string        // optional source of the current keymasters
+	KeymastersRefresh     time.Duration // how long fetched keymasters are served from cache
+	KeymastersHideContact bool          // whether phone numbers and email addresses of keymasters are removed
+
+	GitHubOrg     string        // optional GitHub organization whose repositories are listed as projects
+	GitHubAPIURL  string        // base URL of the GitHub API
+	GitHubRefresh time.Duration // how long fetched repositories are served from cache
+
+	Feeds Feeds // feeds of the space, unconfigured feeds are nil
+
+	DirectoryRegister bool          // whether the SpaceAPI directory is notified that our endpoint is alive
+	DirectoryURL      string        // URL of the SpaceAPI directory heartbeats are posted to
+	DirectoryInterval time.Duration // interval of the SpaceAPI directory heartbeats
+	PublicURL         string        // public base URL of this server, as announced to the directory
+
+	MQTTBroker     string // optional MQTT broker the open state is published to, e.g. tcp://localhost:1883
+	MQTTClientID   string // client id used when connecting to the MQTT broker
+	MQTTUsername   string // optional username for the MQTT broker
+	MQTTPassword   string // optional password for the MQTT broker
+	MQTTStateTopic string // topic the open state is published to as a retained message
+
+	MQTTSensorTopics []MQTTSensorTopic // MQTT topics sensor readings are received on
+	MQTTSensorMaxAge time.Duration     // readings received over MQTT older than this are dropped
+
+	RateLimitPerMinute int            // requests per minute allowed per client, zero disables rate limiting
+	RateLimitBurst     int            // requests a client may make at once before being limited
+	TrustedProxies     []netip.Prefix // proxies whose X-Forwarded-For header is honored to identify clients
+
+	StateMessagesOpen   map[string]string // message while open by language, used if neither the state api nor an override sets one
+	StateMessagesClosed map[string]string // message while closed by language, used if neither the state api nor an override sets one
+	DefaultLanguage     string            // language of the message used if none matches the Accept-Language of the request
+
+	UpstreamMaxIdleConns    int           // idle keep-alive connections kept open per upstream host
+	UpstreamIdleConnTimeout time.Duration // how long idle upstream connections are kept open
+
+	RequestTimeout time.Duration // how long a handler may take before the request is answered with 503, zero disables it
+
+	Extensions map[string]json.RawMessage // ext_ prefixed fields added to the top level of the document
+
+	StateSourceFile      string // optional file a door controller writes the lab state to, used instead of the state apis
+	StateSourceFileWatch bool   // whether changes of the state source file are picked up immediately instead of polled
+}
+
+// configErrors collects the invalid settings, so they can all be fixed at once instead of one per restart
+type configErrors []error
+
+// add records err if it is not nil
+func (e *configErrors) add(err error) {
+	if err != nil {
+		*e = append(*e, err)
+	}
+}
+
+func (e configErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Unwrap allows errors.Is and errors.As to inspect every collected error
+func (e configErrors) Unwrap() []error {
+	return e
+}
+
+// LoadConfig reads the configuration from the environment, falling back to defaults,
+// all invalid settings are reported together
+func LoadConfig() (Config, error) {
+	var errs configErrors
+
+	stateFetchTimeout, err := getEnvDuration("STATE_FETCH_TIMEOUT", 5*time.Second)
+	errs.add(err)
+	stateFetchAttempts, err := getEnvInt("STATE_FETCH_ATTEMPTS", 3)
+	errs.add(err)
+	if stateFetchAttempts < 1 {
+		errs.add(fmt.Errorf("invalid int for STATE_FETCH_ATTEMPTS: must be at least 1"))
+	}
+	stateFetchRetryDelay, err := getEnvDuration("STATE_FETCH_RETRY_DELAY", 200*time.Millisecond)
+	errs.add(err)
+	stateFetchRetryJitter, err := getEnvDuration("STATE_FETCH_RETRY_JITTER", 100*time.Millisecond)
+	errs.add(err)
+	stateFetchDeadline, err := getEnvDuration("STATE_FETCH_DEADLINE", 10*time.Second)
+	errs.add(err)
+	stateStatusPath := getEnv("STATE_STATUS_PATH", "/status")
+	if err := validateJSONPointer(stateStatusPath); err != nil {
+		errs.add(fmt.Errorf("invalid STATE_STATUS_PATH: %w", err))
+	}
+	stateOpenTokens := getEnvList("STATE_OPEN_TOKENS", []string{"open", "on"})
+	stateClosedTokens := getEnvList("STATE_CLOSED_TOKENS", []string{"closed", "off"})
+	for _, token := range stateOpenTokens {
+		if slices.ContainsFunc(stateClosedTokens, func(t string) bool { return strings.EqualFold(t, token) }) {
+			errs.add(fmt.Errorf("invalid STATE_OPEN_TOKENS: %q is also a closed token", token))
+		}
+	}
+	//STATE_API_URL is kept for deployments with a single state api
+	stateAPIURLs := getEnvList("STATE_API_URLS", []string{getEnv("STATE_API_URL", "https://eingang.metalab.at/status.json")})
+	if len(stateAPIURLs) == 0 {
+		errs.add(fmt.Errorf("invalid STATE_API_URLS: must contain at least one url"))
+	}
+	stateMergeStrategy := getEnv("STATE_MERGE_STRATEGY", "first")
+	if !slices.Contains(stateMergeStrategies, stateMergeStrategy) {
+		errs.add(fmt.Errorf("invalid STATE_MERGE_STRATEGY %q: must be one of first, or, and", stateMergeStrategy))
+	}
+	stateCacheTTL, err := getEnvDuration("STATE_CACHE_TTL", 30*time.Second)
+	errs.add(err)
+
+	cacheTTLJitter, err := getEnvOptionalDuration("CACHE_TTL_JITTER", 0)
+	errs.add(err)
+	stateRefreshInterval, err := getEnvDuration("STATE_REFRESH_INTERVAL", stateCacheTTL)
+	errs.add(err)
+	//cached responses are at most as old as the cached state by default
+	cacheMaxAge, err := getEnvDuration("CACHE_MAX_AGE", stateCacheTTL)
+	errs.add(err)
+
+	shutdownTimeout, err := getEnvDuration("SHUTDOWN_TIMEOUT", 10*time.Second)
+	errs.add(err)
+	var logLevel slog.Level
+	if err := logLevel.UnmarshalText([]byte(getEnv("LOG_LEVEL", "info"))); err != nil {
+		errs.add(fmt.Errorf("invalid LOG_LEVEL: %w", err))
+	}
+	logFormat := strings.ToLower(getEnv("LOG_FORMAT", "text"))
+	if logFormat != "text" && logFormat != "json" {
+		errs.add(fmt.Errorf("invalid LOG_FORMAT %q: must be text or json", logFormat))
+	}
+	logRequests, err := getEnvBool("LOG_REQUESTS", true)
+	errs.add(err)
+	strictValidation, err := getEnvBool("STRICT_VALIDATION", false)
+	errs.add(err)
+	temperatureUnit := getEnv("TEMPERATURE_UNIT", "°C")
+	if !validTemperatureUnit(temperatureUnit) {
+		errs.add(fmt.Errorf("invalid TEMPERATURE_UNIT %q: must be one of °C, °F, K", temperatureUnit))
+	}
+	radiationTypes := getEnvList("RADIATION_TYPES", []string{"gamma"})
+	for _, radiationType := range radiationTypes {
+		if !validRadiationType(radiationType) {
+			errs.add(fmt.Errorf("invalid RADIATION_TYPES entry %q: must be one of alpha, beta, gamma, beta_gamma", radiationType))
+		}
+	}
+	co2MaxAge, err := getEnvDuration("CO2_MAX_AGE", 15*time.Minute)
+	errs.add(err)
+	sensorMaxStale, err := getEnvDuration("SENSOR_MAX_STALE", 15*time.Minute)
+	errs.add(err)
+	eventsLookahead, err := getEnvDuration("EVENTS_LOOKAHEAD", 7*24*time.Hour)
+	errs.add(err)
+	eventsMax, err := getEnvInt("EVENTS_MAX", 10)
+	errs.add(err)
+	mastodonAccount := getEnv("MASTODON_ACCOUNT", "")
+	if mastodonAccount != "" {
+		if _, _, err := parseMastodonAccount(mastodonAccount); err != nil {
+			errs.add(fmt.Errorf("invalid MASTODON_ACCOUNT: %w", err))
+		}
+	}
+	mastodonRefresh, err := getEnvDuration("MASTODON_REFRESH", 15*time.Minute)
+	errs.add(err)
+	stateOverrideDuration, err := getEnvOptionalDuration("STATE_OVERRIDE_DURATION", 0)
+	errs.add(err)
+	streamHeartbeat, err := getEnvDuration("STREAM_HEARTBEAT", 15*time.Second)
+	errs.add(err)
+	exposeTriggerPerson, err := getEnvBool("EXPOSE_TRIGGER_PERSON", false)
+	errs.add(err)
+	historySize, err := getEnvInt("HISTORY_SIZE", 50)
+	errs.add(err)
+	if historySize < 1 {
+		errs.add(fmt.Errorf("invalid int for HISTORY_SIZE: must be at least 1"))
+	}
+	webhookTimeout, err := getEnvDuration("WEBHOOK_TIMEOUT", 5*time.Second)
+	errs.add(err)
+	webhookAttempts, err := getEnvInt("WEBHOOK_ATTEMPTS", 3)
+	errs.add(err)
+	if webhookAttempts < 1 {
+		errs.add(fmt.Errorf("invalid int for WEBHOOK_ATTEMPTS: must be at least 1"))
+	}
+	keymastersRefresh, err := getEnvDuration("KEYMASTERS_REFRESH", time.Hour)
+	errs.add(err)
+	keymastersHideContact, err := getEnvBool("KEYMASTERS_HIDE_CONTACT", false)
+	errs.add(err)
+	//unauthenticated requests to the GitHub API are limited to 60 per hour
+	gitHubRefresh, err := getEnvDuration("GITHUB_REFRESH", 6*time.Hour)
+	errs.add(err)
+	directoryRegister, err := getEnvBool("DIRECTORY_REGISTER", false)
+	errs.add(err)
+	directoryInterval, err := getEnvDuration("DIRECTORY_INTERVAL", 24*time.Hour)
+	errs.add(err)
+	directoryURL := getEnv("DIRECTORY_URL", "")
+	publicURL := getEnv("PUBLIC_URL", "")
+	//registering must be explicit, so forks don't announce someone else's endpoint
+	if directoryRegister && (directoryURL == "" || publicURL == "") {
+		errs.add(fmt.Errorf("DIRECTORY_REGISTER requires DIRECTORY_URL and PUBLIC_URL"))
+	}
+	mqttSensorTopics, err := parseMQTTSensorTopics(getEnvList("MQTT_SENSOR_TOPICS", nil))
+	errs.add(err)
+	mqttBroker := getEnv("MQTT_BROKER", "")
+	if len(mqttSensorTopics) > 0 && mqttBroker == "" {
+		errs.add(fmt.Errorf("MQTT_SENSOR_TOPICS requires MQTT_BROKER"))
+	}
+	mqttSensorMaxAge, err := getEnvDuration("MQTT_SENSOR_MAX_AGE", 15*time.Minute)
+	errs.add(err)
+	stateIconOpen, stateIconClosed, err := getStateIcons()
+	errs.add(err)
+	listenAddr := getEnv("LISTEN_ADDR", ":3334")
+	errs.add(validateListenAddr(listenAddr))
+	listenSocketMode, err := parseFileMode(getEnv("LISTEN_SOCKET_MODE", "0660"))
+	if err != nil {
+		errs.add(fmt.Errorf("invalid LISTEN_SOCKET_MODE: %w", err))
+	}
+	radioShowRRule := getEnv("RADIO_SHOW_RRULE", "")
+	if radioShowRRule != "" {
+		if _, err := parseRRule(radioShowRRule, time.UTC); err != nil {
+			errs.add(fmt.Errorf("invalid RADIO_SHOW_RRULE: %w", err))
+		}
+	}
+	//rate limiting is opt-in, behind a reverse proxy without TRUSTED_PROXIES every client shares the proxy address
+	rateLimitPerMinute, err := getEnvInt("RATE_LIMIT_PER_MINUTE", 0)
+	errs.add(err)
+	rateLimitBurst, err := getEnvInt("RATE_LIMIT_BURST", 30)
+	errs.add(err)
+	if rateLimitBurst < 1 {
+		errs.add(fmt.Errorf("invalid int for RATE_LIMIT_BURST: must be at least 1"))
+	}
+	trustedProxies, err := parseTrustedProxies(getEnvList("TRUSTED_PROXIES", nil))
+	errs.add(err)
+	defaultLanguage := strings.ToLower(getEnv("DEFAULT_LANGUAGE", "en"))
+	stateMessagesOpen, err := getEnvMessages("STATE_MESSAGES_OPEN", defaultLanguage)
+	errs.add(err)
+	stateMessagesClosed, err := getEnvMessages("STATE_MESSAGES_CLOSED", defaultLanguage)
+	errs.add(err)
+	upstreamMaxIdleConns, err := getEnvInt("UPSTREAM_MAX_IDLE_CONNS", 10)
+	errs.add(err)
+	upstreamIdleConnTimeout, err := getEnvDuration("UPSTREAM_IDLE_CONN_TIMEOUT", 90*time.Second)
+	errs.add(err)
+	extensions, err := getEnvExtensions("SPACE_EXTENSIONS")
+	errs.add(err)
+	stateSourceFileWatch, err := getEnvBool("STATE_SOURCE_FILE_WATCH", false)
+	errs.add(err)
+	requestTimeout, err := getEnvOptionalDuration("REQUEST_TIMEOUT", 15*time.Second)
+	errs.add(err)
+	//a slow state api should end in the cached state or an error naming the state api, not in a generic timeout
+	if requestTimeout != 0 && (requestTimeout <= stateFetchDeadline || requestTimeout <= stateFetchTimeout) {
+		errs.add(fmt.Errorf("invalid REQUEST_TIMEOUT: must be longer than STATE_FETCH_DEADLINE (%s) and STATE_FETCH_TIMEOUT (%s)", stateFetchDeadline, stateFetchTimeout))
+	}
+	tlsCertFile := getEnv("TLS_CERT_FILE", "")
+	tlsKeyFile := getEnv("TLS_KEY_FILE", "")
+	errs.add(validateTLSFiles(tlsCertFile, tlsKeyFile))
+
+	if len(errs) > 0 {
+		return Config{}, errs
+	}
+
+	return Config{
+		StateAPIURLs:      stateAPIURLs,
+		StateFetchTimeout: stateFetchTimeout,
+		StateCacheTTL:     stateCacheTTL,
+		CacheTTLJitter:    cacheTTLJitter,
+		CacheMaxAge:       cacheMaxAge,
+		ListenAddr:        listenAddr,
+		ListenSocketMode:  listenSocketMode,
+		TLSCertFile:       tlsCertFile,
+		TLSKeyFile:        tlsKeyFile,
+		ShutdownTimeout:   shutdownTimeout,
+		LogLevel:          logLevel,
+		LogFormat:         logFormat,
+		LogRequests:       logRequests,
+		ConfigFile:        getEnv("CONFIG_FILE", ""),
+		StateFile:         getEnv("STATE_FILE", ""),
+		AllowedOrigins:    getEnvList("ALLOWED_ORIGINS", nil),
+		StrictValidation:  strictValidation,
+		StreamHeartbeat:   streamHeartbeat,
+		HistorySize:       historySize,
+		HistoryFile:       getEnv("HISTORY_FILE", ""),
+
+		WebhookURLs:     getEnvList("WEBHOOK_URLS", nil),
+		WebhookTimeout:  webhookTimeout,
+		WebhookAttempts: webhookAttempts,
+
+		StateFetchAttempts:    stateFetchAttempts,
+		StateFetchRetryDelay:  stateFetchRetryDelay,
+		StateFetchRetryJitter: stateFetchRetryJitter,
+		StateFetchDeadline:    stateFetchDeadline,
+		StateRefreshInterval:  stateRefreshInterval,
+		StateStatusPath:       stateStatusPath,
+		StateOpenTokens:       stateOpenTokens,
+		StateClosedTokens:     stateClosedTokens,
+		StateMergeStrategy:    stateMergeStrategy,
+
+		AdminToken:            getEnv("ADMIN_TOKEN", ""),
+		StateOverrideDuration: stateOverrideDuration,
+
+		TemperatureSensorsURL: getEnv("TEMPERATURE_SENSORS_URL", ""),
+		TemperatureUnit:       temperatureUnit,
+		CO2SensorsURL:         getEnv("CO2_SENSORS_URL", ""),
+		CO2MaxAge:             co2MaxAge,
+		DoorLockURL:           getEnv("DOOR_LOCK_URL", ""),
+		BeverageSupplyURL:     getEnv("BEVERAGE_SUPPLY_URL", ""),
+		HumiditySensorsURL:    getEnv("HUMIDITY_SENSORS_URL", ""),
+		BarometerSensorsURL:   getEnv("BAROMETER_SENSORS_URL", ""),
+		RadiationSensorsURL:   getEnv("RADIATION_SENSORS_URL", ""),
+		RadiationTypes:        radiationTypes,
+		SensorMaxStale:        sensorMaxStale,
+
+		EventsCalendarURL: getEnv("EVENTS_CALENDAR_URL", ""),
+		EventsLookahead:   eventsLookahead,
+		EventsMax:         eventsMax,
+		MastodonAccount:   mastodonAccount,
+		MastodonTag:       strings.TrimPrefix(getEnv("MASTODON_TAG", "event"), "#"),
+		MastodonRefresh:   mastodonRefresh,
+
+		ExposeTriggerPerson: exposeTriggerPerson,
+		StateIconOpen:       stateIconOpen,
+		StateIconClosed:     stateIconClosed,
+
+		CamURLs:        getEnvList("CAM_URLS", nil),
+		RadioShowRRule: radioShowRRule,
+
+		KeymastersURL:         getEnv("KEYMASTERS_URL", ""),
+		KeymastersRefresh:     keymastersRefresh,
+		KeymastersHideContact: keymastersHideContact,
+
+		GitHubOrg:     getEnv("GITHUB_ORG", ""),
+		GitHubAPIURL:  getEnv("GITHUB_API_URL", "https://api.github.com"),
+		GitHubRefresh: gitHubRefresh,
+
+		Feeds: Feeds{
+			Blog:     getEnvFeed("FEED_BLOG"),
+			Wiki:     getEnvFeed("FEED_WIKI"),
+			Calendar: getEnvFeed("FEED_CALENDAR"),
+			Flickr:   getEnvFeed("FEED_FLICKR"),
+		},
+
+		DirectoryRegister: directoryRegister,
+		DirectoryURL:      directoryURL,
+		DirectoryInterval: directoryInterval,
+		PublicURL:         publicURL,
+
+		MQTTBroker:     mqttBroker,
+		MQTTClientID:   getEnv("MQTT_CLIENT_ID", "metalab-spaceapi"),
+		MQTTUsername:   getEnv("MQTT_USERNAME", ""),
+		MQTTPassword:   getEnv("MQTT_PASSWORD", ""),
+		MQTTStateTopic: getEnv("MQTT_STATE_TOPIC", "metalab/spaceapi/state"),
+
+		MQTTSensorTopics: mqttSensorTopics,
+		MQTTSensorMaxAge: mqttSensorMaxAge,
+
+		RateLimitPerMinute: rateLimitPerMinute,
+		RateLimitBurst:     rateLimitBurst,
+		TrustedProxies:     trustedProxies,
+
+		StateMessagesOpen:   stateMessagesOpen,
+		StateMessagesClosed: stateMessagesClosed,
+		DefaultLanguage:     defaultLanguage,
+
+		UpstreamMaxIdleConns:    upstreamMaxIdleConns,
+		UpstreamIdleConnTimeout: upstreamIdleConnTimeout,
+
+		RequestTimeout: requestTimeout,
+
+		Extensions: extensions,
+
+		StateSourceFile:      getEnv("STATE_SOURCE_FILE", ""),
+		StateSourceFileWatch: stateSourceFileWatch,
+	}, nil
+}
+
+// getEnvMessages parses the environment variable key as a JSON object of messages by language, e.g.
+// {"en":"open","de":"offen"}, which must include the default language
+func getEnvMessages(key, defaultLanguage string) (map[string]string, error) {
+	value := getEnv(key, "")
+	if value == "" {
+		return nil, nil
+	}
+	var messages map[string]string
+	if err := json.Unmarshal([]byte(value), &messages); err != nil {
+		return nil, fmt.Errorf("invalid messages for %s: %w", key, err)
+	}
+	//language tags are case insensitive
+	variants := make(map[string]string, len(messages))
+	for language, message := range messages {
+		variants[strings.ToLower(language)] = message
+	}
+	if _, ok := variants[defaultLanguage]; !ok {
+		return nil, fmt.Errorf("invalid messages for %s: no message in the default language %q", key, defaultLanguage)
+	}
+	return variants, nil
+}
+
+// getEnvFeed reads the feed from the environment variables prefix_URL and prefix_TYPE, or returns nil if no url is set
+func getEnvFeed(prefix string) *Feed {
+	url := getEnv(prefix+"_URL", "")
+	if url == "" {
+		return nil
+	}
+	return &Feed{Type: getEnv(prefix+"_TYPE", ""), URL: url}
+}
+
+// getStateIcons returns the configured state icon URLs, falling back to the Metalab icons if neither is set,
+// setting both to an empty value disables the icons
+func getStateIcons() (open, closed string, err error) {
+	open, openSet := os.LookupEnv("STATE_ICON_OPEN")
+	closed, closedSet := os.LookupEnv("STATE_ICON_CLOSED")
+	if !openSet && !closedSet {
+		return "https://metalab.at/static/images/open.png", "https://metalab.at/static/images/closed.png", nil
+	}
+	//the schema requires both icons
+	if (open == "") != (closed == "") {
+		return "", "", fmt.Errorf("STATE_ICON_OPEN and STATE_ICON_CLOSED must be set together")
+	}
+	return open, closed, nil
+}
+
+// getEnv returns the value of the environment variable key, or fallback if it is unset or empty
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getEnvList splits the comma separated environment variable key, or returns fallback if it is unset or empty
+func getEnvList(key string, fallback []string) []string {
+	value := getEnv(key, "")
+	if value == "" {
+		return fallback
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getEnvDuration parses the environment variable key as a time.Duration, or returns fallback if it is unset, empty or invalid
+func getEnvDuration(key string, fallback time.Duration) (time.Duration, error) {
+	value := getEnv(key, "")
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fallback, fmt.Errorf("invalid duration for %s: %w", key, err)
+	}
+	if d <= 0 {
+		return fallback, fmt.Errorf("invalid duration for %s: must be positive", key)
+	}
+	return d, nil
+}
+
+// getEnvOptionalDuration parses the environment variable key as a non-negative time.Duration, for settings where
+// zero has a meaning of its own, or returns fallback if it is unset, empty or invalid
+func getEnvOptionalDuration(key string, fallback time.Duration) (time.Duration, error) {
+	value := getEnv(key, "")
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fallback, fmt.Errorf("invalid duration for %s: %w", key, err)
+	}
+	if d < 0 {
+		return fallback, fmt.Errorf("invalid duration for %s: must not be negative", key)
+	}
+	return d, nil
+}
+
+// getEnvInt parses the environment variable key as a non-negative int, or returns fallback if it is unset, empty or invalid
+func getEnvInt(key string, fallback int) (int, error) {
+	value := getEnv(key, "")
+	if value == "" {
+		return fallback, nil
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return fallback, fmt.Errorf("invalid int for %s: %w", key, err)
+	}
+	if i < 0 {
+		return fallback, fmt.Errorf("invalid int for %s: must not be negative", key)
+	}
+	return i, nil
+}
+
+// getEnvBool parses the environment variable key as a bool, or returns fallback if it is unset, empty or invalid
+func getEnvBool(key string, fallback bool) (bool, error) {
+	value := getEnv(key, "")
+	if value == "" {
+		return fallback, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return fallback, fmt.Errorf("invalid bool for %s: %w", key, err)
+	}
+	return b, nil
+}
+
+// validateTLSFiles checks that the certificate and key are either both set or both unset, and that they exist
+func validateTLSFiles(certFile, keyFile string) error {
+	if (certFile == "") != (keyFile == "") {
+		return fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	for _, file := range []string{certFile, keyFile} {
+		if file == "" {
+			continue
+		}
+		if _, err := os.Stat(file); err != nil {
+			return fmt.Errorf("invalid TLS file: %w", err)
+		}
+	}
+	return nil
+}
+
+// validateListenAddr checks that addr is a valid host:port pair
+func validateListenAddr(addr string) error {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		if path == "" {
+			return fmt.Errorf("invalid LISTEN_ADDR %q: missing socket path", addr)
+		}
+		return nil
+	}
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid LISTEN_ADDR %q: %w", addr, err)
+	}
+	if p, err := strconv.Atoi(port); err != nil || p < 0 || p > 65535 {
+		return fmt.Errorf("invalid LISTEN_ADDR %q: invalid port %q", addr, port)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigDefaults(t *testing.T) {
+	config := testConfig(t)
+	if config.StateFetchTimeout != 5*time.Second || config.StateCacheTTL != 30*time.Second || config.RequestTimeout != 15*time.Second {
+		t.Errorf("timeouts = %s, %s, %s, want the documented defaults", config.StateFetchTimeout, config.StateCacheTTL, config.RequestTimeout)
+	}
+	//settings derived from others follow them
+	if config.StateRefreshInterval != config.StateCacheTTL || config.CacheMaxAge != config.StateCacheTTL {
+		t.Errorf("refresh interval = %s, max age = %s, want the cache ttl %s", config.StateRefreshInterval, config.CacheMaxAge, config.StateCacheTTL)
+	}
+	if !slices.Equal(config.StateAPIURLs, []string{"https://eingang.metalab.at/status.json"}) {
+		t.Errorf("state api urls = %q, want the Metalab state api", config.StateAPIURLs)
+	}
+	if config.ListenAddr != ":3334" || config.AllowedOrigins != nil || config.AdminToken != "" {
+		t.Errorf("listen addr = %q, origins = %q, admin token = %q, want the defaults", config.ListenAddr, config.AllowedOrigins, config.AdminToken)
+	}
+	if config.RateLimitPerMinute != 0 {
+		t.Errorf("rate limit = %d per minute, want rate limiting disabled", config.RateLimitPerMinute)
+	}
+}
+
+func TestLoadConfigParsing(t *testing.T) {
+	t.Setenv("STATE_CACHE_TTL", "1m30s")
+	t.Setenv("CACHE_TTL_JITTER", "0")
+	t.Setenv("STATE_FETCH_ATTEMPTS", "5")
+	t.Setenv("LOG_REQUESTS", "false")
+	t.Setenv("ALLOWED_ORIGINS", " https://metalab.at, ,https://spaceapi.io ")
+	t.Setenv("STATE_API_URL", "https://door.example/status.json")
+	t.Setenv("REQUEST_TIMEOUT", "0")
+	t.Setenv("LOG_LEVEL", "DEBUG")
+	t.Setenv("LOG_FORMAT", "JSON")
+
+	config := testConfig(t)
+	if config.StateCacheTTL != 90*time.Second {
+		t.Errorf("state cache ttl = %s, want 1m30s", config.StateCacheTTL)
+	}
+	if config.StateRefreshInterval != 90*time.Second {
+		t.Errorf("state refresh interval = %s, want the cache ttl", config.StateRefreshInterval)
+	}
+	if config.CacheTTLJitter != 0 || config.RequestTimeout != 0 {
+		t.Errorf("jitter = %s, request timeout = %s, want both disabled", config.CacheTTLJitter, config.RequestTimeout)
+	}
+	if config.StateFetchAttempts != 5 || config.LogRequests {
+		t.Errorf("attempts = %d, log requests = %v, want 5, false", config.StateFetchAttempts, config.LogRequests)
+	}
+	if config.LogLevel != slog.LevelDebug || config.LogFormat != "json" {
+		t.Errorf("log level = %s, log format = %q, want DEBUG, json", config.LogLevel, config.LogFormat)
+	}
+	if want := []string{"https://metalab.at", "https://spaceapi.io"}; !slices.Equal(config.AllowedOrigins, want) {
+		t.Errorf("allowed origins = %q, want %q", config.AllowedOrigins, want)
+	}
+	//the single url is still honored without STATE_API_URLS
+	if want := []string{"https://door.example/status.json"}; !slices.Equal(config.StateAPIURLs, want) {
+		t.Errorf("state api urls = %q, want %q", config.StateAPIURLs, want)
+	}
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	tests := []struct {
+		key, value string
+		want       string
+	}{
+		{"STATE_CACHE_TTL", "30", "invalid duration for STATE_CACHE_TTL"},
+		{"STATE_CACHE_TTL", "0s", "must be positive"},
+		{"CACHE_TTL_JITTER", "-1s", "must not be negative"},
+		{"STATE_FETCH_ATTEMPTS", "three", "invalid int for STATE_FETCH_ATTEMPTS"},
+		{"STATE_FETCH_ATTEMPTS", "0", "must be at least 1"},
+		{"LOG_REQUESTS", "maybe", "LOG_REQUESTS"},
+		{"STATE_MERGE_STRATEGY", "xor", "invalid STATE_MERGE_STRATEGY"},
+		{"STATE_STATUS_PATH", "door/state", "invalid STATE_STATUS_PATH"},
+		{"STATE_CLOSED_TOKENS", "OPEN", "also a closed token"},
+		{"LISTEN_ADDR", "localhost:http-alt", "invalid LISTEN_ADDR"},
+		{"TRUSTED_PROXIES", "proxy.local", "invalid TRUSTED_PROXIES"},
+		{"REQUEST_TIMEOUT", "3s", "must be longer than STATE_FETCH_DEADLINE"},
+		{"SPACE_EXTENSIONS", `{"stats": 1}`, "does not start with ext_"},
+		{"RADIO_SHOW_RRULE", "FREQ=MONTHLY;BYDAY=1FR", "invalid RADIO_SHOW_RRULE"},
+		{"LOG_LEVEL", "verbose", "invalid LOG_LEVEL"},
+		{"LOG_FORMAT", "xml", "invalid LOG_FORMAT"},
+	}
+	for _, test := range tests {
+		t.Run(test.key+"="+test.value, func(t *testing.T) {
+			t.Setenv(test.key, test.value)
+			_, err := LoadConfig()
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("LoadConfig() = %v, want an error containing %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigAggregatesErrors(t *testing.T) {
+	t.Setenv("STATE_CACHE_TTL", "soon")
+	t.Setenv("EVENTS_MAX", "-1")
+	t.Setenv("TEMPERATURE_UNIT", "°R")
+
+	_, err := LoadConfig()
+	var errs configErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("LoadConfig() = %v, want all errors collected", err)
+	}
+	if len(errs) != 3 {
+		t.Errorf("LoadConfig() reported %d errors, want 3: %v", len(errs), err)
+	}
+	for _, key := range []string{"STATE_CACHE_TTL", "EVENTS_MAX", "TEMPERATURE_UNIT"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("LoadConfig() = %v, want it to mention %s", err, key)
+		}
+	}
+}
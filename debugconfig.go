@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"time"
+)
+
+// secretConfigFields are reported as redacted instead of their value
+var secretConfigFields = map[string]bool{
+	"AdminToken":   true,
+	"MQTTPassword": true,
+}
+
+// secretURLConfigFields hold URLs that carry a token in their path or query, like most chat webhooks,
+// only their host is reported
+var secretURLConfigFields = map[string]bool{
+	"WebhookURLs": true,
+}
+
+// redactedConfig returns the effective configuration keyed by field name with secrets and passwords in URLs redacted
+func redactedConfig(config Config) map[string]any {
+	redacted := make(map[string]any)
+	v := reflect.ValueOf(config)
+	for i := range v.NumField() {
+		name := v.Type().Field(i).Name
+		switch value := v.Field(i).Interface().(type) {
+		case string:
+			if secretConfigFields[name] && value != "" {
+				redacted[name] = "[redacted]"
+			} else {
+				redacted[name] = redactURL(value)
+			}
+		case []string:
+			values := make([]string, len(value))
+			for i, value := range value {
+				if secretURLConfigFields[name] {
+					values[i] = redactURLPath(value)
+				} else {
+					values[i] = redactURL(value)
+				}
+			}
+			redacted[name] = values
+		case time.Duration:
+			redacted[name] = value.String()
+		case os.FileMode:
+			redacted[name] = fmt.Sprintf("%#o", value)
+		default:
+			redacted[name] = value
+		}
+	}
+	return redacted
+}
+
+// redactURL replaces the password of a URL with credentials, anything else is returned as is
+func redactURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil {
+		return value
+	}
+	return u.Redacted()
+}
+
+// redactURLPath reduces a URL to its scheme and host, the rest is redacted
+func redactURLPath(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" {
+		return "[redacted]"
+	}
+	return u.Scheme + "://" + u.Host + "/[redacted]"
+}
+
+// handleDebugConfig reports the configuration the server is running with, including the applied defaults
+func (s *Server) handleDebugConfig(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	s.writeJSON(w, r, redactedConfig(s.config))
+}
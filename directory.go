@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// directoryAttempts is the maximum number of requests per heartbeat
+const directoryAttempts = 3
+
+// DirectoryHeartbeat is the body posted to the SpaceAPI directory
+type DirectoryHeartbeat struct {
+	URL string `json:"url"`
+}
+
+// runDirectoryHeartbeat notifies the SpaceAPI directory that our endpoint is alive, right away and then every interval
+func (s *Server) runDirectoryHeartbeat(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.sendDirectoryHeartbeat(ctx); err != nil && ctx.Err() == nil {
+			s.logger.Error("spaceapi directory heartbeat failed", "url", s.config.DirectoryURL, "attempts", directoryAttempts, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sendDirectoryHeartbeat posts a heartbeat to the directory, retrying failed requests with exponential backoff
+func (s *Server) sendDirectoryHeartbeat(ctx context.Context) error {
+	var lastErr error
+	for attempt := 1; attempt <= directoryAttempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-time.After(retryDelay(attempt-1, time.Second, 500*time.Millisecond)):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		lastErr = s.postDirectoryHeartbeat(ctx)
+		if lastErr == nil {
+			s.logger.Debug("spaceapi directory heartbeat sent", "url", s.config.DirectoryURL, "endpoint", s.config.PublicURL, "attempt", attempt)
+			return nil
+		}
+		s.logger.Warn("spaceapi directory heartbeat request failed", "url", s.config.DirectoryURL, "attempt", attempt, "error", lastErr)
+	}
+	return lastErr
+}
+
+// postDirectoryHeartbeat performs a single heartbeat request bounded by the state fetch timeout
+func (s *Server) postDirectoryHeartbeat(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, s.config.StateFetchTimeout)
+	defer cancel()
+
+	req, err := newDirectoryHeartbeatRequest(ctx, s.config.DirectoryURL, s.config.PublicURL)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("spaceapi directory returned status %d (%s)", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	return nil
+}
+
+// newDirectoryHeartbeatRequest builds the request announcing the v15 endpoint below publicURL to the directory
+func newDirectoryHeartbeatRequest(ctx context.Context, directoryURL, publicURL string) (*http.Request, error) {
+	endpoint, err := url.JoinPath(publicURL, "v15")
+	if err != nil {
+		return nil, fmt.Errorf("invalid public url %q: %w", publicURL, err)
+	}
+	payload, err := json.Marshal(DirectoryHeartbeat{URL: endpoint})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", directoryURL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewDirectoryHeartbeatRequest(t *testing.T) {
+	for _, publicURL := range []string{"https://spaceapi.metalab.at", "https://spaceapi.metalab.at/"} {
+		req, err := newDirectoryHeartbeatRequest(context.Background(), "https://api.spaceapi.io/heartbeat", publicURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if req.Method != "POST" || req.URL.String() != "https://api.spaceapi.io/heartbeat" {
+			t.Errorf("request = %s %s, want POST to the directory", req.Method, req.URL)
+		}
+		if contentType := req.Header.Get("Content-Type"); contentType != "application/json" {
+			t.Errorf("Content-Type = %q, want application/json", contentType)
+		}
+		var heartbeat DirectoryHeartbeat
+		if err := json.NewDecoder(req.Body).Decode(&heartbeat); err != nil {
+			t.Fatal(err)
+		}
+		if heartbeat.URL != "https://spaceapi.metalab.at/v15" {
+			t.Errorf("public url %q: heartbeat url = %q, want the v15 endpoint", publicURL, heartbeat.URL)
+		}
+	}
+
+	if _, err := newDirectoryHeartbeatRequest(context.Background(), "https://api.spaceapi.io/heartbeat", "://invalid"); err == nil {
+		t.Error("newDirectoryHeartbeatRequest() with an invalid public url succeeded")
+	}
+}
+
+func TestSendDirectoryHeartbeat(t *testing.T) {
+	heartbeats := make(chan DirectoryHeartbeat, 1)
+	directory := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var heartbeat DirectoryHeartbeat
+		json.NewDecoder(r.Body).Decode(&heartbeat)
+		heartbeats <- heartbeat
+	}))
+	defer directory.Close()
+
+	config := testConfig(t)
+	config.DirectoryURL = directory.URL
+	config.PublicURL = "https://spaceapi.metalab.at"
+	s, _ := newTestServer(t, config, staticState(LabState{}))
+	if err := s.sendDirectoryHeartbeat(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if heartbeat := <-heartbeats; heartbeat.URL != "https://spaceapi.metalab.at/v15" {
+		t.Errorf("heartbeat url = %q, want the v15 endpoint", heartbeat.URL)
+	}
+}
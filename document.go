@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+
+	"sigs.k8s.io/yaml"
+)
+
+// loadSpaceDocument reads the static parts of the SpaceAPI document from a JSON or YAML file
+func loadSpaceDocument(path string) (*SpaceAPIv15, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	//yaml is a superset of json, so this handles both and honors the json struct tags
+	var doc SpaceAPIv15
+	if err := yaml.UnmarshalStrict(content, &doc); err != nil {
+		return nil, fmt.Errorf("error while parsing space document %s: %w", path, err)
+	}
+
+	//the state is always filled in at runtime
+	if doc.State == nil {
+		doc.State = &State{}
+	}
+	if doc.Location != nil {
+		if err := validateAreas(doc.Location.Areas); err != nil {
+			return nil, fmt.Errorf("error while validating space document %s: %w", path, err)
+		}
+	}
+	return &doc, nil
+}
+
+// validateAreas checks that every area has a positive size, as the schema requires it
+func validateAreas(areas []Area) error {
+	for i, area := range areas {
+		if area.SquareMeters <= 0 {
+			return fmt.Errorf("area %d (%q) must have positive square_meters", i, area.Name)
+		}
+	}
+	return nil
+}
+
+// mergeFeeds returns the feeds of the document with every configured feed replacing the one of the same kind,
+// configured feeds with an invalid url are logged and skipped, nil is returned if no feed is left
+func (s *Server) mergeFeeds(static *Feeds, configured Feeds) *Feeds {
+	var feeds Feeds
+	if static != nil {
+		feeds = *static
+	}
+	for _, feed := range []struct {
+		kind   string
+		target **Feed
+		value  *Feed
+	}{
+		{"blog", &feeds.Blog, configured.Blog},
+		{"wiki", &feeds.Wiki, configured.Wiki},
+		{"calendar", &feeds.Calendar, configured.Calendar},
+		{"flickr", &feeds.Flickr, configured.Flickr},
+	} {
+		if feed.value == nil {
+			continue
+		}
+		if err := validateURL(feed.value.URL); err != nil {
+			s.logger.Warn("skipping invalid feed", "kind", feed.kind, "url", feed.value.URL, "error", err)
+			continue
+		}
+		*feed.target = feed.value
+	}
+
+	if feeds == (Feeds{}) {
+		return nil
+	}
+	return &feeds
+}
+
+// validURLs returns the well-formed absolute URLs in urls, logging and skipping the others
+func (s *Server) validURLs(kind string, urls []string) []string {
+	var valid []string
+	for _, u := range urls {
+		if err := validateURL(u); err != nil {
+			s.logger.Warn("skipping invalid url", "kind", kind, "url", u, "error", err)
+			continue
+		}
+		valid = append(valid, u)
+	}
+	return valid
+}
+
+// validateURL checks that u is an absolute URL with a scheme and a host
+func validateURL(u string) error {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme == "" || parsed.Host == "" {
+		return fmt.Errorf("not an absolute url")
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeSpaceDocument writes content to a space document file in a temporary directory and returns its path
+func writeSpaceDocument(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadSpaceDocumentAreas(t *testing.T) {
+	path := writeSpaceDocument(t, "space.yaml", `
+space: Metalab
+location:
+  address: Rathausstraße 6, 1010 Wien, Austria
+  areas:
+    - name: Hauptraum
+      description: The main room with the big table
+      square_meters: 80
+    - name: Werkstatt
+      square_meters: 25.5
+`)
+	doc, err := loadSpaceDocument(path)
+	if err != nil {
+		t.Fatalf("loadSpaceDocument() = %v", err)
+	}
+	want := []Area{
+		{Name: "Hauptraum", Description: "The main room with the big table", SquareMeters: 80},
+		{Name: "Werkstatt", SquareMeters: 25.5},
+	}
+	if len(doc.Location.Areas) != len(want) {
+		t.Fatalf("areas = %+v, want %+v", doc.Location.Areas, want)
+	}
+	for i, area := range doc.Location.Areas {
+		if area != want[i] {
+			t.Errorf("areas[%d] = %+v, want %+v", i, area, want[i])
+		}
+	}
+}
+
+func TestLoadSpaceDocumentInvalidArea(t *testing.T) {
+	tests := []struct {
+		name string
+		area string
+	}{
+		{"missing square meters", `{"name": "Hauptraum"}`},
+		{"zero square meters", `{"name": "Hauptraum", "square_meters": 0}`},
+		{"negative square meters", `{"name": "Hauptraum", "square_meters": -5}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := writeSpaceDocument(t, "space.json", `{"space": "Metalab", "location": {"areas": [`+test.area+`]}}`)
+			_, err := loadSpaceDocument(path)
+			if err == nil || !strings.Contains(err.Error(), "square_meters") {
+				t.Errorf("loadSpaceDocument() = %v, want an error about square_meters", err)
+			}
+		})
+	}
+}
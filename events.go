@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+// calendarEvent is an event parsed from an iCalendar feed
+type calendarEvent struct {
+	UID        string
+	Summary    string
+	Category   string
+	URL        string
+	Start      time.Time
+	Recurrence *recurrenceRule // nil for a single event
+	Exceptions []time.Time     // occurrences of the series that are left out
+	//set if the event replaces the occurrence of the series with the same UID at that time
+	RecurrenceID time.Time
+}
+
+// occurrencesUntil returns the start of every occurrence of the event up to end
+func (e calendarEvent) occurrencesUntil(end time.Time) []time.Time {
+	var starts []time.Time
+	if e.Recurrence == nil {
+		if !e.Start.After(end) {
+			starts = append(starts, e.Start)
+		}
+		return starts
+	}
+	for start := range e.Recurrence.occurrences(e.Start) {
+		if start.After(end) {
+			break
+		}
+		if !slices.ContainsFunc(e.Exceptions, start.Equal) {
+			starts = append(starts, start)
+		}
+	}
+	return starts
+}
+
+// fetchEvents fetches the configured calendar and returns the upcoming events within the look-ahead window
+func (s *Server) fetchEvents() ([]Event, error) {
+	body, err := s.fetchBody(s.config.EventsCalendarURL, "text/calendar")
+	if err != nil {
+		s.logger.Warn("events calendar unavailable", "url", s.config.EventsCalendarURL, "error", err)
+		return nil, err
+	}
+
+	calendarEvents, err := parseICal(bytes.NewReader(body), s.spaceLocation())
+	if err != nil {
+		s.logger.Warn("invalid events calendar", "url", s.config.EventsCalendarURL, "error", err)
+		return nil, err
+	}
+	return upcomingEvents(calendarEvents, s.clock.Now(), s.config.EventsLookahead, s.config.EventsMax), nil
+}
+
+// upcomingEvents returns at most max events starting between now and now+lookahead, ordered by start, recurring
+// events are included with every occurrence in that window
+func upcomingEvents(calendarEvents []calendarEvent, now time.Time, lookahead time.Duration, max int) []Event {
+	var upcoming []calendarEvent
+	for _, e := range calendarEvents {
+		for _, start := range e.occurrencesUntil(now.Add(lookahead)) {
+			if !start.Before(now) {
+				occurrence := e
+				occurrence.Start = start
+				upcoming = append(upcoming, occurrence)
+			}
+		}
+	}
+	slices.SortFunc(upcoming, func(a, b calendarEvent) int {
+		return a.Start.Compare(b.Start)
+	})
+	if len(upcoming) > max {
+		upcoming = upcoming[:max]
+	}
+
+	events := make([]Event, 0, len(upcoming))
+	for _, e := range upcoming {
+		eventType := e.Category
+		if eventType == "" {
+			eventType = "event"
+		}
+		events = append(events, Event{
+			Name:      e.Summary,
+			Type:      eventType,
+			Timestamp: e.Start.Unix(),
+			Extra:     e.URL,
+		})
+	}
+	return events
+}
+
+// spaceLocation returns the time zone of the space, UTC if the document doesn't set one
+func (s *Server) spaceLocation() *time.Location {
+	return s.location
+}
+
+// loadTimezone loads the time zone of the document and canonicalizes its name, it falls back to UTC if none is set
+func loadTimezone(doc *SpaceAPIv15) (*time.Location, error) {
+	if doc.Location == nil || strings.TrimSpace(doc.Location.Timezone) == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(strings.TrimSpace(doc.Location.Timezone))
+	if err != nil {
+		return nil, fmt.Errorf("invalid location.timezone %q: %w", doc.Location.Timezone, err)
+	}
+	doc.Location.Timezone = loc.String()
+	return loc, nil
+}
+
+// parseICal extracts the events of an iCalendar feed, floating times are interpreted in defaultLoc, a recurring event
+// with a rule that isn't supported is kept with its first occurrence only
+func parseICal(r io.Reader, defaultLoc *time.Location) ([]calendarEvent, error) {
+	lines, err := unfoldICalLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []calendarEvent
+	var current *calendarEvent
+	var rrule string
+	for _, line := range lines {
+		name, params, value := parseICalLine(line)
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			current = &calendarEvent{}
+			rrule = ""
+		case name == "END" && value == "VEVENT":
+			if current != nil && !current.Start.IsZero() {
+				//the rule is parsed once the start is known, UNTIL may be given as a date in its time zone
+				if rrule != "" {
+					current.Recurrence, _ = parseRRule(rrule, current.Start.Location())
+				}
+				events = append(events, *current)
+			}
+			current = nil
+		case current == nil:
+			continue
+		case name == "SUMMARY":
+			current.Summary = unescapeICalText(value)
+		case name == "CATEGORIES":
+			category, _, _ := strings.Cut(value, ",")
+			current.Category = unescapeICalText(category)
+		case name == "URL":
+			current.URL = value
+		case name == "DTSTART":
+			start, err := parseICalTime(value, params, defaultLoc)
+			if err != nil {
+				return nil, err
+			}
+			current.Start = start
+		case name == "UID":
+			current.UID = value
+		case name == "RECURRENCE-ID":
+			recurrenceID, err := parseICalTime(value, params, defaultLoc)
+			if err != nil {
+				return nil, err
+			}
+			current.RecurrenceID = recurrenceID
+		case name == "RRULE":
+			rrule = value
+		case name == "EXDATE":
+			for _, v := range strings.Split(value, ",") {
+				exception, err := parseICalTime(v, params, defaultLoc)
+				if err != nil {
+					return nil, err
+				}
+				current.Exceptions = append(current.Exceptions, exception)
+			}
+		}
+	}
+
+	//a modified occurrence replaces the one of its series
+	for _, e := range events {
+		if e.RecurrenceID.IsZero() {
+			continue
+		}
+		for i := range events {
+			if events[i].UID == e.UID && events[i].Recurrence != nil {
+				events[i].Exceptions = append(events[i].Exceptions, e.RecurrenceID)
+			}
+		}
+	}
+	return events, nil
+}
+
+// unfoldICalLines reads the content lines of an iCalendar feed, joining folded continuation lines
+func unfoldICalLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// parseICalLine splits a content line into its upper-cased name, parameters and value
+func parseICalLine(line string) (name string, params map[string]string, value string) {
+	head, value, _ := strings.Cut(line, ":")
+	parts := strings.Split(head, ";")
+	params = make(map[string]string)
+	for _, param := range parts[1:] {
+		k, v, _ := strings.Cut(param, "=")
+		params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+	}
+	return strings.ToUpper(parts[0]), params, value
+}
+
+// parseICalTime parses a DATE or DATE-TIME value, honoring the TZID parameter
+func parseICalTime(value string, params map[string]string, defaultLoc *time.Location) (time.Time, error) {
+	loc := defaultLoc
+	if tzid, ok := params["TZID"]; ok {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+
+	switch {
+	case params["VALUE"] == "DATE" || len(value) == 8:
+		return time.ParseInLocation("20060102", value, loc)
+	case strings.HasSuffix(value, "Z"):
+		return time.Parse("20060102T150405Z", value)
+	default:
+		t, err := time.ParseInLocation("20060102T150405", value, loc)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid iCalendar time %q: %w", value, err)
+		}
+		return t, nil
+	}
+}
+
+// unescapeICalText reverts the escaping of iCalendar TEXT values
+func unescapeICalText(value string) string {
+	return strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(value)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestFetchEvents(t *testing.T) {
+	calendar := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/calendar")
+		http.ServeFile(w, r, "testdata/events.ics")
+	}))
+	defer calendar.Close()
+
+	config := testConfig(t)
+	config.EventsCalendarURL = calendar.URL
+	config.EventsLookahead = 7 * 24 * time.Hour
+	config.EventsMax = 10
+	s, _ := newTestServer(t, config, staticState(LabState{}))
+
+	events, err := s.fetchEvents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vienna, _ := time.LoadLocation("Europe/Vienna")
+	//the past events and the ones after the look-ahead window are left out, recurring events are included with
+	//every occurrence within the window, except the excluded and the moved one, the monthly rule isn't supported
+	//and only its past first occurrence is kept
+	want := []Event{
+		{Name: "Linux Stammtisch", Type: "meetup", Timestamp: time.Date(2024, 3, 2, 19, 0, 0, 0, vienna).Unix(), Extra: "https://metalab.at/wiki/Linux_Stammtisch"},
+		{Name: "Lötkurs", Type: "workshop", Timestamp: time.Date(2024, 3, 2, 20, 0, 0, 0, vienna).Unix()},
+		{Name: "Lötkurs", Type: "workshop", Timestamp: time.Date(2024, 3, 4, 20, 0, 0, 0, vienna).Unix()},
+		{Name: "Repair Café", Type: "workshop", Timestamp: time.Date(2024, 3, 5, 0, 0, 0, 0, vienna).Unix()},
+		{Name: "Hackathon: Tür, Licht und Sensoren", Type: "event", Timestamp: time.Date(2024, 3, 7, 17, 0, 0, 0, time.UTC).Unix()},
+		{Name: "Spieleabend", Type: "event", Timestamp: time.Date(2024, 3, 7, 20, 0, 0, 0, vienna).Unix()},
+	}
+	if !slices.Equal(events, want) {
+		t.Errorf("events = %+v, want %+v", events, want)
+	}
+
+	s.config.EventsMax = 2
+	events, err = s.fetchEvents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(events, want[:2]) {
+		t.Errorf("events capped at 2 = %+v, want %+v", events, want[:2])
+	}
+}
+
+func TestLoadTimezone(t *testing.T) {
+	tests := []struct {
+		timezone string
+		want     string
+		ok       bool
+	}{
+		{"Europe/Vienna", "Europe/Vienna", true},
+		{" Europe/Vienna ", "Europe/Vienna", true},
+		{"", "UTC", true},
+		{"Europe/Metalab", "", false},
+		{"CEST", "", false},
+	}
+	for _, test := range tests {
+		doc := &SpaceAPIv15{Location: &Location{Timezone: test.timezone}}
+		loc, err := loadTimezone(doc)
+		if (err == nil) != test.ok {
+			t.Errorf("loadTimezone(%q) = %v, want ok %v", test.timezone, err, test.ok)
+			continue
+		}
+		if test.ok && (loc.String() != test.want || (test.timezone != "" && doc.Location.Timezone != test.want)) {
+			t.Errorf("loadTimezone(%q) = %s, timezone %q, want %s", test.timezone, loc, doc.Location.Timezone, test.want)
+		}
+	}
+}
+
+func TestNewServerInvalidTimezone(t *testing.T) {
+	doc := defaultSpaceDocument()
+	doc.Location.Timezone = "Europe/Metalab"
+	_, err := NewServer(WithConfig(testConfig(t)), WithDocument(doc), WithStateFetcher(staticState(LabState{})))
+	if err == nil {
+		t.Error("NewServer() with a bogus timezone succeeded, want an error")
+	}
+
+	//an empty ZONEINFO directory falls back to the system or embedded time zone database
+	t.Setenv("ZONEINFO", t.TempDir())
+	s, _ := newTestServer(t, testConfig(t), staticState(LabState{}))
+	if s.location.String() != "Europe/Vienna" {
+		t.Errorf("location = %s, want Europe/Vienna", s.location)
+	}
+}
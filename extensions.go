@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// MarshalJSON marshals the document with its extension fields added at the top level, after the standard fields
+func (doc SpaceAPIv15) MarshalJSON() ([]byte, error) {
+	//the conversion drops the methods, so this doesn't recurse
+	type document SpaceAPIv15
+	p, err := json.Marshal(document(doc))
+	if err != nil || len(doc.Extensions) == 0 {
+		return p, err
+	}
+
+	var buf bytes.Buffer
+	buf.Write(p[:len(p)-1])
+	for _, key := range slices.Sorted(maps.Keys(doc.Extensions)) {
+		name, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteByte(',')
+		buf.Write(name)
+		buf.WriteByte(':')
+		if err := json.Compact(&buf, doc.Extensions[key]); err != nil {
+			return nil, fmt.Errorf("invalid extension %s: %w", key, err)
+		}
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// getEnvExtensions parses the environment variable key as a JSON object of SpaceAPI extension fields, e.g.
+// {"ext_metalab_wiki_stats":{"pages":1234}}, every key must start with ext_
+func getEnvExtensions(key string) (map[string]json.RawMessage, error) {
+	value := getEnv(key, "")
+	if value == "" {
+		return nil, nil
+	}
+	var extensions map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(value), &extensions); err != nil {
+		return nil, fmt.Errorf("invalid extensions for %s: %w", key, err)
+	}
+	for name := range extensions {
+		if !strings.HasPrefix(name, "ext_") || name == "ext_" {
+			return nil, fmt.Errorf("invalid extensions for %s: %q does not start with ext_", key, name)
+		}
+	}
+	return extensions, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestGetEnvExtensions(t *testing.T) {
+	tests := []struct {
+		value string
+		want  map[string]string
+		ok    bool
+	}{
+		{"", nil, true},
+		{`{"ext_metalab_wiki_stats": {"pages": 1234}}`, map[string]string{"ext_metalab_wiki_stats": `{"pages": 1234}`}, true},
+		{`{"ext_a": 1, "ext_b": "two"}`, map[string]string{"ext_a": "1", "ext_b": `"two"`}, true},
+		{`{"wiki_stats": {}}`, nil, false},
+		{`{"ext_": 1}`, nil, false},
+		{`{"EXT_stats": 1}`, nil, false},
+		{`["ext_stats"]`, nil, false},
+		{`{"ext_stats":`, nil, false},
+	}
+	for _, test := range tests {
+		t.Setenv("SPACE_EXTENSIONS", test.value)
+		extensions, err := getEnvExtensions("SPACE_EXTENSIONS")
+		if (err == nil) != test.ok {
+			t.Errorf("getEnvExtensions(%s) = %v, want ok %v", test.value, err, test.ok)
+			continue
+		}
+		if len(extensions) != len(test.want) {
+			t.Errorf("getEnvExtensions(%s) = %s, want %v", test.value, extensions, test.want)
+			continue
+		}
+		for key, want := range test.want {
+			if string(extensions[key]) != want {
+				t.Errorf("getEnvExtensions(%s)[%s] = %s, want %s", test.value, key, extensions[key], want)
+			}
+		}
+	}
+}
+
+func TestExtensionsInDocument(t *testing.T) {
+	t.Setenv("SPACE_EXTENSIONS", `{"ext_metalab_wiki_stats": {"pages": 1234, "edits": 56789}, "ext_habitat": "indoor"}`)
+	s, _ := newTestServer(t, testConfig(t), staticState(LabState{Open: Pointer(true)}))
+	w := serve(s, "GET", "/v15", nil)
+
+	doc := decodeJSON[map[string]json.RawMessage](t, w)
+	if stats := string(doc["ext_metalab_wiki_stats"]); stats != `{"pages":1234,"edits":56789}` {
+		t.Errorf("ext_metalab_wiki_stats = %s, want the configured extension", stats)
+	}
+	if habitat := string(doc["ext_habitat"]); habitat != `"indoor"` {
+		t.Errorf("ext_habitat = %s, want the configured extension", habitat)
+	}
+	if doc["space"] == nil || doc["state"] == nil {
+		t.Error("standard fields are missing next to the extensions")
+	}
+	//extensions follow the standard fields in a stable order
+	body := w.Body.String()
+	if i, j := strings.Index(body, `"ext_habitat"`), strings.Index(body, `"ext_metalab_wiki_stats"`); i < strings.Index(body, `"state"`) || j < i {
+		t.Errorf("body = %s, want the extensions sorted after the standard fields", body)
+	}
+
+	//the document with extensions still passes the schema, which allows ext_ fields
+	if result := decodeJSON[ValidationResult](t, serve(s, "GET", "/v15/validate", nil)); !result.Valid {
+		t.Errorf("violations = %v, want the document with extensions to be valid", result.Violations)
+	}
+}
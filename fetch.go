@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// newHTTPClient returns the client shared by all upstream requests so their connections are kept alive and reused,
+// timeouts are set per request
+func newHTTPClient(config Config) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = config.UpstreamMaxIdleConns
+	transport.IdleConnTimeout = config.UpstreamIdleConnTimeout
+	return &http.Client{Transport: transport}
+}
+
+// upstreamContext bounds how long a request waits for the upstreams besides the state api, a slow one is served
+// from cache or left out instead of running into the request timeout
+func (s *Server) upstreamContext(r *http.Request) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(r.Context(), s.config.StateFetchDeadline)
+}
+
+// fetchBody requests url and returns the response body, non-2xx responses are treated as errors
+func (s *Server) fetchBody(url, accept string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.StateFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.logger.Error("error while sending request", "url", url, "error", err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		s.logger.Error("unexpected response status", "url", url, "status", resp.StatusCode)
+		return nil, fmt.Errorf("%s returned status %d (%s)", url, resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		s.logger.Error("error while reading response body", "url", url, "status", resp.StatusCode, "error", err)
+		return nil, err
+	}
+	return body, nil
+}
+
+// fetchJSON requests url and unmarshals the JSON response body into v
+func (s *Server) fetchJSON(url string, v any) error {
+	body, err := s.fetchBody(url, "application/json")
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		s.logger.Error("error while unmarshalling response body", "url", url, "error", err)
+		return fmt.Errorf("error while unmarshalling response %q from %s: %w", truncate(string(body), 100), url, err)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// alwaysIncludedFields are kept in a filtered document, so it still identifies the space and the api version
+var alwaysIncludedFields = []string{"api_compatibility", "space"}
+
+// requestedFields returns the top-level fields asked for with e.g. ?fields=state,location, or nil if all fields are wanted
+func requestedFields(r *http.Request) []string {
+	var fields []string
+	for _, field := range strings.Split(r.URL.Query().Get("fields"), ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// selectFields clears every top-level field of doc that isn't in fields, all of them are omitted when empty,
+// the requested names that aren't fields of the document are returned
+func selectFields(doc *SpaceAPIv15, fields []string) (unknown []string) {
+	v := reflect.ValueOf(doc).Elem()
+	known := make(map[string]bool, v.NumField())
+	for i := range v.NumField() {
+		name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		known[name] = true
+		if !slices.Contains(fields, name) && !slices.Contains(alwaysIncludedFields, name) {
+			v.Field(i).SetZero()
+		}
+	}
+	//the extensions map is shared with the static document
+	extensions := maps.Clone(doc.Extensions)
+	maps.DeleteFunc(extensions, func(name string, _ json.RawMessage) bool {
+		known[name] = true
+		return !slices.Contains(fields, name)
+	})
+	doc.Extensions = extensions
+	for _, field := range fields {
+		if !known[field] {
+			unknown = append(unknown, field)
+		}
+	}
+	return unknown
+}
+
+// filterFields reduces doc to the fields requested with ?fields, it is a no-op if the parameter is missing
+func (s *Server) filterFields(r *http.Request, doc *SpaceAPIv15) {
+	fields := requestedFields(r)
+	if fields == nil {
+		return
+	}
+	for _, field := range selectFields(doc, fields) {
+		s.logger.Warn("ignoring unknown field", "path", r.URL.Path, "field", field)
+	}
+}
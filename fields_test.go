@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestRequestedFields(t *testing.T) {
+	tests := []struct {
+		target string
+		want   []string
+	}{
+		{"/v15", nil},
+		{"/v15?fields=", nil},
+		{"/v15?fields=state", []string{"state"}},
+		{"/v15?fields=state,%20location,,contact", []string{"state", "location", "contact"}},
+	}
+	for _, test := range tests {
+		if got := requestedFields(httptest.NewRequest("GET", test.target, nil)); !slices.Equal(got, test.want) {
+			t.Errorf("requestedFields(%s) = %q, want %q", test.target, got, test.want)
+		}
+	}
+}
+
+func TestSelectFields(t *testing.T) {
+	static := defaultSpaceDocument()
+	static.Extensions = map[string]json.RawMessage{"ext_bar": json.RawMessage(`{"open":true}`), "ext_habitat": json.RawMessage(`"indoor"`)}
+	doc := *static
+	unknown := selectFields(&doc, []string{"location", "ext_bar", "bogus"})
+
+	if !slices.Equal(unknown, []string{"bogus"}) {
+		t.Errorf("unknown fields = %q, want bogus", unknown)
+	}
+	if doc.Space != "Metalab" || len(doc.APICompatibility) == 0 || doc.Location == nil {
+		t.Errorf("document = %+v, want api_compatibility, space and location kept", doc)
+	}
+	if doc.Logo != "" || doc.URL != "" || doc.Contact != nil || doc.Projects != nil || doc.State != nil {
+		t.Errorf("document = %+v, want every other field cleared", doc)
+	}
+	if got := slices.Sorted(maps.Keys(doc.Extensions)); !slices.Equal(got, []string{"ext_bar"}) {
+		t.Errorf("extensions = %q, want only ext_bar", got)
+	}
+	if len(static.Extensions) != 2 {
+		t.Errorf("extensions of the static document = %q, want them untouched", slices.Sorted(maps.Keys(static.Extensions)))
+	}
+}
+
+func TestSpaceAPIv13Fields(t *testing.T) {
+	s, _ := newTestServer(t, testConfig(t), staticState(LabState{Open: Pointer(true)}))
+	doc := decodeJSON[map[string]json.RawMessage](t, serve(s, "GET", "/v13?fields=state", nil))
+	//the fields v13 requires are kept, the optional ones are filtered
+	for _, field := range []string{"api", "space", "logo", "url", "location", "contact", "state"} {
+		if _, ok := doc[field]; !ok {
+			t.Errorf("required field %s is missing", field)
+		}
+	}
+	if _, ok := doc["projects"]; ok {
+		t.Error("projects are included, want them filtered")
+	}
+}
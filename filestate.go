@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// stateFileSettleDelay is how long the state file has to be left alone after a change before it is read,
+// writers usually truncate and write it in separate steps
+const stateFileSettleDelay = 100 * time.Millisecond
+
+// stateWatcher is implemented by fetchers that can tell when the lab state changed instead of being polled
+type stateWatcher interface {
+	watch(ctx context.Context, changed func()) error
+}
+
+// FileStateFetcher reads the lab state from a local file written by a door controller, the file contains either
+// a state token like open or closed, or a JSON object like the responses of the state api
+type FileStateFetcher struct {
+	path   string
+	config Config
+	logger *slog.Logger
+
+	mu         sync.Mutex
+	open       *bool  // open state last read from the file
+	lastChange *int64 // modification time of the file when the open state last changed
+}
+
+// NewFileStateFetcher returns a fetcher for the state file at path, the state tokens and status path are taken from config
+func NewFileStateFetcher(path string, config Config, logger *slog.Logger) *FileStateFetcher {
+	return &FileStateFetcher{path: path, config: config, logger: logger}
+}
+
+// Fetch reads the state file, the state is unknown while there is none, unless the file contains the last change
+// it is the modification time of the file at which the open state was first read
+func (f *FileStateFetcher) Fetch(ctx context.Context) (LabState, error) {
+	file, err := os.Open(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		f.changedAt(nil, time.Time{})
+		return LabState{}, nil
+	}
+	if err != nil {
+		return LabState{}, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return LabState{}, err
+	}
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return LabState{}, err
+	}
+	content = bytes.TrimSpace(content)
+
+	var state LabState
+	switch {
+	case len(content) == 0:
+		return LabState{}, fmt.Errorf("state file %s is empty", f.path)
+	case content[0] == '{':
+		if state, err = parseLabState(f.config, content); err != nil {
+			return LabState{}, fmt.Errorf("invalid state file %s: %w", f.path, err)
+		}
+	default:
+		open, err := parseStatus(f.config, string(content))
+		if err != nil {
+			return LabState{}, fmt.Errorf("invalid state file %s: %w", f.path, err)
+		}
+		state.Open = Pointer(open)
+	}
+	if state.LastChange == nil {
+		state.LastChange = f.changedAt(state.Open, info.ModTime())
+	}
+	return state, nil
+}
+
+// changedAt returns the modification time of the file when the open state last changed, door controllers may
+// rewrite the file without the state changing, a nil open state forgets the last change
+func (f *FileStateFetcher) changedAt(open *bool, modified time.Time) *int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if open == nil {
+		f.open, f.lastChange = nil, nil
+		return nil
+	}
+	if f.lastChange == nil || !equalState(f.open, open) {
+		f.open, f.lastChange = open, Pointer(modified.Unix())
+	}
+	return f.lastChange
+}
+
+// watch calls changed whenever the state file was written, created or removed, until ctx is cancelled
+func (f *FileStateFetcher) watch(ctx context.Context, changed func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	//the directory is watched, as the file may not exist yet or be replaced by renaming a new one over it
+	if err := watcher.Add(filepath.Dir(f.path)); err != nil {
+		return err
+	}
+
+	path := filepath.Clean(f.path)
+	var settled <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if filepath.Clean(event.Name) == path {
+				settled = time.After(stateFileSettleDelay)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			f.logger.Warn("error while watching the state file", "path", f.path, "error", err)
+		case <-settled:
+			settled = nil
+			changed()
+		}
+	}
+}
+
+// runStateWatcher refreshes the lab state as soon as the fetcher notices a change
+func (s *Server) runStateWatcher(ctx context.Context, watcher stateWatcher) {
+	err := watcher.watch(ctx, func() {
+		if _, err := s.refreshLabState(ctx); err != nil {
+			s.logger.Warn("error while refreshing the changed lab state", "error", err)
+		}
+	})
+	if err != nil {
+		s.logger.Error("error while watching the lab state, falling back to polling", "error", err)
+	}
+}
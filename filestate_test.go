@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeStateFile writes content to the state file at path and sets its modification time
+func writeStateFile(t *testing.T, path, content string, modified time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestFileFetcher(t *testing.T) (*FileStateFetcher, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "state")
+	return NewFileStateFetcher(path, testConfig(t), slog.New(slog.NewTextHandler(io.Discard, nil))), path
+}
+
+func TestFileStateFetcher(t *testing.T) {
+	tests := []struct {
+		content string
+		want    *bool
+		ok      bool
+	}{
+		{"open", Pointer(true), true},
+		{"CLOSED\n", Pointer(false), true},
+		{"1", Pointer(true), true},
+		{`{"status":"closed","last_changed":1700000000}`, Pointer(false), true},
+		{"ajar", nil, false},
+		{"  \n", nil, false},
+		{`{"status":`, nil, false},
+	}
+	for _, test := range tests {
+		fetcher, path := newTestFileFetcher(t)
+		writeStateFile(t, path, test.content, testNow)
+		state, err := fetcher.Fetch(context.Background())
+		if (err == nil) != test.ok || !equalState(state.Open, test.want) {
+			t.Errorf("Fetch() of %q = %s, %v, want %s, ok %v", test.content, formatState(state.Open), err, formatState(test.want), test.ok)
+		}
+	}
+
+	fetcher, _ := newTestFileFetcher(t)
+	state, err := fetcher.Fetch(context.Background())
+	if err != nil || state.Open != nil || state.LastChange != nil {
+		t.Errorf("Fetch() without a state file = %s since %v, %v, want unknown", formatState(state.Open), state.LastChange, err)
+	}
+}
+
+func TestFileStateFetcherLastChange(t *testing.T) {
+	fetcher, path := newTestFileFetcher(t)
+	steps := []struct {
+		content  string // empty removes the file
+		modified time.Time
+		want     *int64
+	}{
+		{"open", testNow, Pointer(testNow.Unix())},
+		//door controllers rewrite the file periodically without the state changing
+		{"open", testNow.Add(time.Minute), Pointer(testNow.Unix())},
+		{"closed", testNow.Add(2 * time.Minute), Pointer(testNow.Add(2 * time.Minute).Unix())},
+		{"", time.Time{}, nil},
+		{"closed", testNow.Add(3 * time.Minute), Pointer(testNow.Add(3 * time.Minute).Unix())},
+		{`{"status":"open","last_changed":1700000000}`, testNow.Add(4 * time.Minute), Pointer[int64](1700000000)},
+	}
+	for i, step := range steps {
+		if step.content == "" {
+			os.Remove(path)
+		} else {
+			writeStateFile(t, path, step.content, step.modified)
+		}
+		state, err := fetcher.Fetch(context.Background())
+		if err != nil {
+			t.Fatalf("step %d: Fetch() = %v", i, err)
+		}
+		if (state.LastChange == nil) != (step.want == nil) || (step.want != nil && *state.LastChange != *step.want) {
+			t.Errorf("step %d: lastchange = %v, want %v", i, formatLastChange(state.LastChange), formatLastChange(step.want))
+		}
+	}
+}
+
+// formatLastChange formats an optional unix timestamp for test failures
+func formatLastChange(lastChange *int64) string {
+	if lastChange == nil {
+		return "none"
+	}
+	return time.Unix(*lastChange, 0).UTC().Format(time.RFC3339)
+}
+
+func TestFileStateWatch(t *testing.T) {
+	fetcher, path := newTestFileFetcher(t)
+	writeStateFile(t, path, "closed", testNow)
+	s, _ := newTestServer(t, testConfig(t), fetcher)
+	if _, err := s.refreshLabState(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.runStateWatcher(ctx, fetcher)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	//the watcher may not be set up yet, so the file is rewritten until the change is picked up
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		writeStateFile(t, path, "open", testNow.Add(time.Minute))
+		time.Sleep(2 * stateFileSettleDelay)
+		if state, _ := s.stateCache.last(); equalState(state.Open, Pointer(true)) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the rewritten state file was not picked up without polling")
+		}
+	}
+	if doc := decodeJSON[SpaceAPIv15](t, serve(s, "GET", "/v15", nil)); !equalState(doc.State.Open, Pointer(true)) {
+		t.Errorf("state.open = %s, want open", formatState(doc.State.Open))
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxGitHubPages bounds the number of pages of repositories requested per refresh
+const maxGitHubPages = 10
+
+// gitHubRepository is the part of a GitHub repository that is published as a project
+type gitHubRepository struct {
+	HTMLURL  string `json:"html_url"`
+	Fork     bool   `json:"fork"`
+	Archived bool   `json:"archived"`
+}
+
+// fetchGitHubProjects lists the URLs of the public repositories of the configured GitHub organization,
+// forks and archived repositories are left out
+func (s *Server) fetchGitHubProjects() ([]string, error) {
+	next := fmt.Sprintf("%s/orgs/%s/repos?type=public&sort=full_name&per_page=100", strings.TrimSuffix(s.config.GitHubAPIURL, "/"), url.PathEscape(s.config.GitHubOrg))
+	var projects []string
+	for page := 0; next != "" && page < maxGitHubPages; page++ {
+		var repositories []gitHubRepository
+		var err error
+		next, err = s.fetchGitHub(next, &repositories)
+		if err != nil {
+			s.logger.Warn("github repositories unavailable", "org", s.config.GitHubOrg, "error", err)
+			return nil, err
+		}
+		for _, repository := range repositories {
+			if !repository.Fork && !repository.Archived && repository.HTMLURL != "" {
+				projects = append(projects, repository.HTMLURL)
+			}
+		}
+	}
+	return projects, nil
+}
+
+// fetchGitHub requests a page of the GitHub API and unmarshals it into v, next is the URL of the following page,
+// once the rate limit is used up no further requests are made until it resets
+func (s *Server) fetchGitHub(url string, v any) (next string, err error) {
+	now := s.clock.Now()
+	if until, ok := s.gitHubThrottle.get("github", now); ok {
+		return "", &throttledError{Until: until}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.StateFetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if until, ok := gitHubRateLimitReset(resp, now); ok {
+		s.logger.Warn("github rate limit reached", "until", until)
+		s.gitHubThrottle.set("github", until)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("%s returned status %d (%s)", url, resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return "", fmt.Errorf("error while unmarshalling response %q from %s: %w", truncate(string(body), 100), url, err)
+	}
+	return nextLink(resp.Header.Get("Link")), nil
+}
+
+// gitHubRateLimitReset returns until when requests have to be suspended because the rate limit is used up,
+// which GitHub reports with X-RateLimit-Remaining and, for secondary limits, Retry-After
+func gitHubRateLimitReset(resp *http.Response, now time.Time) (until time.Time, ok bool) {
+	if until, ok := parseRetryAfter(resp.Header.Get("Retry-After"), now); ok {
+		return until, true
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return time.Time{}, false
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil || time.Unix(reset, 0).After(now.Add(maxRetryAfter)) {
+		return now.Add(maxRetryAfter), true
+	}
+	return time.Unix(reset, 0), true
+}
+
+// linkNext matches the next relation of a Link header
+var linkNext = regexp.MustCompile(`<([^>]*)>;\s*rel="next"`)
+
+// nextLink returns the URL of the next page from a Link header, or an empty string on the last page
+func nextLink(header string) string {
+	for _, link := range strings.Split(header, ",") {
+		if match := linkNext.FindStringSubmatch(link); match != nil {
+			return match[1]
+		}
+	}
+	return ""
+}
+
+// expandProjects replaces the organization URL in the static projects with the repositories of the organization,
+// or appends them if the organization isn't listed
+func expandProjects(static []string, orgURL string, repositories []string) []string {
+	i := slices.Index(static, orgURL)
+	if i < 0 {
+		return slices.Concat(static, repositories)
+	}
+	return slices.Concat(static[:i], repositories, static[i+1:])
+}
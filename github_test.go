@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newGitHubAPI serves captured pages of the repositories of the metalab organization
+func newGitHubAPI(t *testing.T) (url string, requests *atomic.Int32) {
+	t.Helper()
+	requests = new(atomic.Int32)
+	var api *httptest.Server
+	api = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path != "/orgs/metalab/repos" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("X-RateLimit-Limit", "60")
+		w.Header().Set("X-RateLimit-Remaining", "57")
+		switch r.URL.Query().Get("page") {
+		case "":
+			w.Header().Set("Link", fmt.Sprintf(`<%[1]s/orgs/metalab/repos?type=public&sort=full_name&per_page=100&page=2>; rel="next", <%[1]s/orgs/metalab/repos?type=public&sort=full_name&per_page=100&page=2>; rel="last"`, api.URL))
+			http.ServeFile(w, r, "testdata/github_repos_page1.json")
+		case "2":
+			w.Header().Set("Link", fmt.Sprintf(`<%[1]s/orgs/metalab/repos?type=public&sort=full_name&per_page=100&page=1>; rel="prev", <%[1]s/orgs/metalab/repos?type=public&sort=full_name&per_page=100&page=1>; rel="first"`, api.URL))
+			http.ServeFile(w, r, "testdata/github_repos_page2.json")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(api.Close)
+	return api.URL, requests
+}
+
+func TestFetchGitHubProjects(t *testing.T) {
+	url, requests := newGitHubAPI(t)
+	config := testConfig(t)
+	config.GitHubOrg = "metalab"
+	config.GitHubAPIURL = url
+	s, _ := newTestServer(t, config, staticState(LabState{Open: Pointer(true)}))
+
+	projects, err := s.fetchGitHubProjects()
+	if err != nil {
+		t.Fatalf("fetchGitHubProjects() = %v", err)
+	}
+	want := []string{"https://github.com/metalab/audio-control", "https://github.com/metalab/metalab-spaceapi", "https://github.com/metalab/website"}
+	if !slices.Equal(projects, want) {
+		t.Errorf("fetchGitHubProjects() = %q, want %q without forks and archived repositories", projects, want)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("api was requested %d times, want once per page", got)
+	}
+
+	doc := decodeJSON[SpaceAPIv15](t, serve(s, "GET", "/v15", nil))
+	want = slices.Concat(want, []string{"https://metalab.at/wiki/Projekte_Neu", "https://metalab.at/project"})
+	if !slices.Equal(doc.Projects, want) {
+		t.Errorf("projects = %q, want %q", doc.Projects, want)
+	}
+}
+
+func TestFetchGitHubProjectsFallback(t *testing.T) {
+	var requests atomic.Int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", fmt.Sprint(testNow.Add(20*time.Minute).Unix()))
+		http.Error(w, `{"message":"API rate limit exceeded"}`, http.StatusForbidden)
+	}))
+	defer api.Close()
+
+	config := testConfig(t)
+	config.GitHubOrg = "metalab"
+	config.GitHubAPIURL = api.URL
+	s, clock := newTestServer(t, config, staticState(LabState{Open: Pointer(true)}))
+
+	doc := decodeJSON[SpaceAPIv15](t, serve(s, "GET", "/v15", nil))
+	if want := defaultSpaceDocument().Projects; !slices.Equal(doc.Projects, want) {
+		t.Errorf("projects = %q, want the static projects %q", doc.Projects, want)
+	}
+	//no requests are made until the rate limit resets
+	clock.Advance(time.Minute)
+	serve(s, "GET", "/v15", nil)
+	if got := requests.Load(); got != 1 {
+		t.Errorf("api was requested %d times, want once until the rate limit resets", got)
+	}
+}
+
+func TestNextLink(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{`<https://api.github.com/organizations/1/repos?page=2>; rel="next", <https://api.github.com/organizations/1/repos?page=4>; rel="last"`, "https://api.github.com/organizations/1/repos?page=2"},
+		{`<https://api.github.com/organizations/1/repos?page=1>; rel="prev"`, ""},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if got := nextLink(test.header); got != test.want {
+			t.Errorf("nextLink(%q) = %q, want %q", test.header, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// handleHealthz is the liveness probe, it never touches the state api
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte(`{"status":"ok"}`))
+}
+
+// ReadinessStatus is the response body of the readiness probe
+type ReadinessStatus struct {
+	Ready       bool   `json:"ready"`
+	LastError   string `json:"last_error,omitempty"`
+	LastSuccess int64  `json:"last_success,omitempty"`
+}
+
+// handleReadyz is the readiness probe, it reports whether the last refresh of the lab state succeeded
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	lastErr, lastSuccess := s.stateCache.status()
+	status := ReadinessStatus{Ready: lastErr == nil && !lastSuccess.IsZero()}
+	if lastErr != nil {
+		status.LastError = lastErr.Error()
+	}
+	if !lastSuccess.IsZero() {
+		status.LastSuccess = lastSuccess.Unix()
+	}
+	p, _ := json.Marshal(status)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if !status.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	w.Write(p)
+}
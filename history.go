@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// Transition is a recorded change of the open state
+type Transition struct {
+	Timestamp int64 `json:"timestamp"`
+	Open      *bool `json:"open"`
+}
+
+// HistoryResponse is the response body of GET /history/state
+type HistoryResponse struct {
+	Open                  *bool        `json:"open"`
+	Since                 int64        `json:"since,omitempty"`
+	CurrentStreakDuration int64        `json:"current_streak_seconds,omitempty"`
+	Transitions           []Transition `json:"transitions"`
+}
+
+// stateHistory is a ring buffer of the most recent state transitions
+type stateHistory struct {
+	mu          sync.RWMutex
+	size        int
+	transitions []Transition // oldest first
+}
+
+func newStateHistory(size int) *stateHistory {
+	return &stateHistory{size: size}
+}
+
+// record appends a transition, dropping the oldest one if the buffer is full,
+// it reports false if the open state didn't actually change compared to the last recorded transition
+func (h *stateHistory) record(t Transition) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if n := len(h.transitions); n > 0 && equalState(h.transitions[n-1].Open, t.Open) {
+		return false
+	}
+	h.transitions = append(h.transitions, t)
+	if len(h.transitions) > h.size {
+		h.transitions = h.transitions[len(h.transitions)-h.size:]
+	}
+	return true
+}
+
+// list returns a copy of the recorded transitions, oldest first
+func (h *stateHistory) list() []Transition {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return append([]Transition{}, h.transitions...)
+}
+
+// save writes the recorded transitions to path
+func (h *stateHistory) save(path string) error {
+	p, err := json.Marshal(h.list())
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, p, 0o644)
+}
+
+// load replaces the recorded transitions with the ones stored at path
+func (h *stateHistory) load(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var transitions []Transition
+	if err := json.Unmarshal(content, &transitions); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(transitions) > h.size {
+		transitions = transitions[len(transitions)-h.size:]
+	}
+	h.transitions = transitions
+	return nil
+}
+
+// recordTransition adds a detected state change to the history and persists it if configured
+func (s *Server) recordTransition(transition stateTransition) {
+	timestamp := transition.At.Unix()
+	if transition.LastChange != nil {
+		timestamp = *transition.LastChange
+	}
+	if !s.stateHistory.record(Transition{Timestamp: timestamp, Open: transition.Open}) {
+		return
+	}
+	if s.config.HistoryFile != "" {
+		if err := s.stateHistory.save(s.config.HistoryFile); err != nil {
+			s.logger.Warn("error while persisting state history", "path", s.config.HistoryFile, "error", err)
+		}
+	}
+}
+
+// restoreHistory loads the persisted history, a missing or corrupt file is ignored
+func (s *Server) restoreHistory(path string) {
+	err := s.stateHistory.load(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		s.logger.Warn("ignoring invalid history file", "path", path, "error", err)
+	}
+}
+
+// handleStateHistory returns the recent state transitions and how long the current state has lasted
+func (s *Server) handleStateHistory(w http.ResponseWriter, r *http.Request) {
+	transitions := s.stateHistory.list()
+	response := HistoryResponse{Transitions: transitions}
+	if n := len(transitions); n > 0 {
+		current := transitions[n-1]
+		response.Open = current.Open
+		response.Since = current.Timestamp
+		response.CurrentStreakDuration = max(0, s.clock.Now().Unix()-current.Timestamp)
+	}
+	s.writeJSON(w, r, response)
+}
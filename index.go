@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+)
+
+// Endpoint describes an available SpaceAPI endpoint
+type Endpoint struct {
+	Path        string `json:"path"`
+	Version     string `json:"version"`
+	Description string `json:"description"`
+}
+
+// Index is the discovery document served at the server root
+type Index struct {
+	Space     string     `json:"space"`
+	Endpoints []Endpoint `json:"endpoints"`
+}
+
+var endpoints = []Endpoint{
+	{Path: "/v13", Version: "0.13", Description: "SpaceAPI v0.13 document for legacy consumers"},
+	{Path: "/v14", Version: "14", Description: "SpaceAPI v14 document, identical to v15"},
+	{Path: "/v15", Version: "15", Description: "SpaceAPI v15 document"},
+}
+
+// handleIndex lists the available SpaceAPI endpoints
+func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	//the root pattern matches every path, only answer for the root itself
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	s.writeJSON(w, r, Index{Space: s.doc.Space, Endpoints: endpoints})
+}
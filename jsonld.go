@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// LocalBusiness is a schema.org LocalBusiness describing the space for search engines and assistants
+type LocalBusiness struct {
+	Context                          string                     `json:"@context"`
+	Type                             string                     `json:"@type"`
+	Name                             string                     `json:"name"`
+	URL                              string                     `json:"url,omitempty"`
+	Logo                             string                     `json:"logo,omitempty"`
+	Telephone                        string                     `json:"telephone,omitempty"`
+	Email                            string                     `json:"email,omitempty"`
+	Address                          *PostalAddress             `json:"address,omitempty"`
+	Geo                              *GeoCoordinates            `json:"geo,omitempty"`
+	SpecialOpeningHoursSpecification *OpeningHoursSpecification `json:"specialOpeningHoursSpecification,omitempty"`
+}
+
+// PostalAddress is a schema.org PostalAddress
+type PostalAddress struct {
+	Type           string `json:"@type"`
+	StreetAddress  string `json:"streetAddress"`
+	AddressCountry string `json:"addressCountry,omitempty"`
+}
+
+// GeoCoordinates is a schema.org GeoCoordinates
+type GeoCoordinates struct {
+	Type      string  `json:"@type"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// OpeningHoursSpecification is a schema.org OpeningHoursSpecification
+type OpeningHoursSpecification struct {
+	Type         string `json:"@type"`
+	Opens        string `json:"opens"`
+	Closes       string `json:"closes"`
+	ValidFrom    string `json:"validFrom"`
+	ValidThrough string `json:"validThrough"`
+}
+
+// todaysOpeningHours describes the current open state as the opening hours of today in the space's time zone,
+// the space is open from the last change until the end of the day, closed is expressed as opening and closing at midnight
+func todaysOpeningHours(state *State, now time.Time, loc *time.Location) *OpeningHoursSpecification {
+	if state.Open == nil {
+		return nil
+	}
+	today := now.In(loc).Format(time.DateOnly)
+	hours := &OpeningHoursSpecification{
+		Type:         "OpeningHoursSpecification",
+		Opens:        "00:00:00",
+		Closes:       "00:00:00",
+		ValidFrom:    today,
+		ValidThrough: today,
+	}
+	if *state.Open {
+		hours.Closes = "23:59:59"
+		//opened on an earlier day means open since the start of today
+		if since := time.Unix(state.LastChange, 0).In(loc); since.Format(time.DateOnly) == today {
+			hours.Opens = since.Format(time.TimeOnly)
+		}
+	}
+	return hours
+}
+
+// handleOpeningJSONLD serves the space and its current open state as schema.org JSON-LD
+func (s *Server) handleOpeningJSONLD(w http.ResponseWriter, r *http.Request) {
+	s.setCacheControl(w)
+	state := s.currentState(w, r)
+
+	business := LocalBusiness{
+		Context:                          "https://schema.org",
+		Type:                             "LocalBusiness",
+		Name:                             s.doc.Space,
+		URL:                              s.doc.URL,
+		Logo:                             s.doc.Logo,
+		SpecialOpeningHoursSpecification: todaysOpeningHours(state, s.clock.Now(), s.spaceLocation()),
+	}
+	if contact := s.doc.Contact; contact != nil {
+		business.Telephone = contact.Phone
+		business.Email = contact.Email
+	}
+	if location := s.doc.Location; location != nil {
+		if location.Address != "" {
+			business.Address = &PostalAddress{Type: "PostalAddress", StreetAddress: location.Address, AddressCountry: location.CountryCode}
+		}
+		business.Geo = &GeoCoordinates{Type: "GeoCoordinates", Latitude: location.Lat, Longitude: location.Lon}
+	}
+	s.writeJSONAs(w, r, "application/ld+json", business)
+}
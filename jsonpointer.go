@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// lookupJSONPointer resolves an RFC 6901 JSON pointer like /door/state against a decoded JSON document,
+// ok is false if the pointer doesn't match anything
+func lookupJSONPointer(doc any, pointer string) (value any, ok bool) {
+	if pointer == "" {
+		return doc, true
+	}
+	value = doc
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		switch v := value.(type) {
+		case map[string]any:
+			if value, ok = v[token]; !ok {
+				return nil, false
+			}
+		case []any:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			value = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// validateJSONPointer checks that pointer is empty or starts with a slash
+func validateJSONPointer(pointer string) error {
+	if pointer != "" && !strings.HasPrefix(pointer, "/") {
+		return fmt.Errorf("invalid json pointer %q: must start with /", pointer)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import "context"
+
+// fetchKeymasters fetches the list of current keymasters from the configured source
+func (s *Server) fetchKeymasters() ([]Keymaster, error) {
+	var keymasters []Keymaster
+	if err := s.fetchJSON(s.config.KeymastersURL, &keymasters); err != nil {
+		s.logger.Warn("keymasters unavailable", "url", s.config.KeymastersURL, "error", err)
+		return nil, err
+	}
+	return keymasters, nil
+}
+
+// currentKeymasters returns the fetched keymasters, falling back to the static ones if the source never answered,
+// phone numbers and email addresses are removed if configured
+func (s *Server) currentKeymasters(ctx context.Context, static []Keymaster) []Keymaster {
+	keymasters := static
+	if s.config.KeymastersURL != "" {
+		if fetched, ok := s.keymastersCache.getOrFetch(ctx, s.fetchKeymasters); ok {
+			keymasters = fetched
+		}
+	}
+	if !s.config.KeymastersHideContact {
+		return keymasters
+	}
+
+	//copy so the cached and static lists stay untouched
+	redacted := make([]Keymaster, 0, len(keymasters))
+	for _, keymaster := range keymasters {
+		keymaster.Phone = ""
+		keymaster.Email = ""
+		redacted = append(redacted, keymaster)
+	}
+	return redacted
+}
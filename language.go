@@ -0,0 +1,55 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// acceptedLanguages returns the language tags of an Accept-Language header, most preferred first
+func acceptedLanguages(header string) []string {
+	type language struct {
+		tag string
+		q   float64
+	}
+	var languages []language
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" && q > 0 {
+			languages = append(languages, language{tag, q})
+		}
+	}
+	//a stable sort keeps the order of the header for equal weights
+	slices.SortStableFunc(languages, func(a, b language) int {
+		return cmp.Compare(b.q, a.q)
+	})
+
+	tags := make([]string, len(languages))
+	for i, language := range languages {
+		tags[i] = language.tag
+	}
+	return tags
+}
+
+// localizedMessage picks the variant of a message best matching the Accept-Language header, a tag like de-AT also
+// matches a de variant, without a match the variant in the fallback language is used
+func localizedMessage(variants map[string]string, acceptLanguage, fallback string) string {
+	for _, tag := range acceptedLanguages(acceptLanguage) {
+		if message, ok := variants[tag]; ok {
+			return message
+		}
+		base, _, _ := strings.Cut(tag, "-")
+		if message, ok := variants[base]; ok {
+			return message
+		}
+	}
+	return variants[fallback]
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAcceptedLanguages(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []string
+	}{
+		{"", []string{}},
+		{"de", []string{"de"}},
+		{"en-US,en;q=0.9,de;q=0.8", []string{"en-us", "en", "de"}},
+		{"de;q=0.5, en", []string{"en", "de"}},
+		{"fr, de", []string{"fr", "de"}},
+		{"de;q=0, en", []string{"en"}},
+		{"de;q=bogus, en", []string{"en"}},
+	}
+	for _, test := range tests {
+		if got := acceptedLanguages(test.header); !slices.Equal(got, test.want) {
+			t.Errorf("acceptedLanguages(%q) = %q, want %q", test.header, got, test.want)
+		}
+	}
+}
+
+func TestLocalizedMessage(t *testing.T) {
+	variants := map[string]string{"en": "open", "de": "offen"}
+	tests := []struct {
+		acceptLanguage string
+		want           string
+	}{
+		{"de", "offen"},
+		{"en", "open"},
+		{"de-AT", "offen"},
+		{"fr, de;q=0.5", "offen"},
+		{"fr", "open"},
+		{"", "open"},
+	}
+	for _, test := range tests {
+		if got := localizedMessage(variants, test.acceptLanguage, "en"); got != test.want {
+			t.Errorf("localizedMessage(%q) = %q, want %q", test.acceptLanguage, got, test.want)
+		}
+	}
+}
+
+func TestStateMessageLanguage(t *testing.T) {
+	config := testConfig(t)
+	config.StateMessagesOpen = map[string]string{"en": "open", "de": "offen"}
+	s, _ := newTestServer(t, config, staticState(LabState{Open: Pointer(true)}))
+	type document struct {
+		State struct {
+			Message string `json:"message"`
+		} `json:"state"`
+	}
+	tests := []struct {
+		acceptLanguage string
+		want           string
+	}{
+		{"de", "offen"},
+		{"en", "open"},
+		{"", "open"},
+	}
+	for _, test := range tests {
+		w := serve(s, "GET", "/v15", http.Header{"Accept-Language": {test.acceptLanguage}})
+		if message := decodeJSON[document](t, w).State.Message; message != test.want {
+			t.Errorf("Accept-Language %q: state.message = %q, want %q", test.acceptLanguage, message, test.want)
+		}
+		if !strings.Contains(strings.Join(w.Header().Values("Vary"), ","), "Accept-Language") {
+			t.Errorf("Accept-Language %q: Vary = %q, want it to include Accept-Language", test.acceptLanguage, w.Header().Values("Vary"))
+		}
+	}
+
+	//a message of the state api is not replaced
+	s, _ = newTestServer(t, config, staticState(LabState{Open: Pointer(true), Message: "closing early today"}))
+	w := serve(s, "GET", "/v15", http.Header{"Accept-Language": {"de"}})
+	if message := decodeJSON[document](t, w).State.Message; message != "closing early today" {
+		t.Errorf("state.message = %q, want the message of the state api", message)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// listen opens a listener on addr, which is either host:port or unix:/path/to.sock,
+// the file of a unix socket is created with the given permissions and removed again when the listener is closed
+func listen(addr string, socketMode os.FileMode) (net.Listener, error) {
+	path, ok := strings.CutPrefix(addr, "unix:")
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+
+	//a socket left behind by a crashed instance would make listening fail, one still in use must be kept
+	if info, err := os.Lstat(path); err == nil && info.Mode().Type() == os.ModeSocket {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("socket %s is in use by another process", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("error while removing stale socket %s: %w", path, err)
+		}
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, socketMode); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("error while setting permissions of socket %s: %w", path, err)
+	}
+	return listener, nil
+}
+
+// parseFileMode parses an octal file mode like 0660
+func parseFileMode(value string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, fmt.Errorf("invalid file mode %q: must be octal permissions like 0660", value)
+	}
+	return os.FileMode(mode), nil
+}
@@ -0,0 +1,15 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+)
+
+// newLogger builds the structured logger according to the configured level and format, both are validated by LoadConfig
+func newLogger(level slog.Level, format string) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level}
+	if format == "json" {
+		return slog.New(slog.NewJSONHandler(os.Stderr, opts))
+	}
+	return slog.New(slog.NewTextHandler(os.Stderr, opts))
+}
@@ -1,383 +1,590 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
+	//the runtime image has no zoneinfo, the time zone of the space document must resolve anyway
+	_ "time/tzdata"
+
+	"github.com/prometheus/client_golang/prometheus"
 )
 
-var spaceApiData = &SpaceAPIv15{
-	APICompatibility: []string{"14", "15"},
-	Space:            "Metalab",
-	Logo:             "https://metalab.at/static/images/logo.png",
-	URL:              "https://metalab.at",
-	Location: &Location{
-		Address:     "Rathausstraße 6, 1010 Vienna, Austria",
-		Lat:         48.2093723,
-		Lon:         16.356099,
-		Timezone:    "Europe/Vienna",
-		CountryCode: "AT",
-	},
-	SpaceFed: &SpaceFed{
-		SpaceNet:  false,
-		SpaceSAML: false,
-	},
-	State: &State{
-		Open: nil,
-	},
-	Contact: &Contact{
-		Phone:     "+43 720 002323",
-		Email:     "core@metalab.at",
-		IssueMail: "core@metalab.at",
-		ML:        "metalab@lists.metalab.at",
-		Mastodon:  "@metalab@chaos.social",
-		SIP:       "6382",
-	},
-	Links: []Link{
-		{
-			Name: "Metalab Wiki",
-			URL:  "https://metalab.at/wiki",
+// defaultSpaceDocument returns the built-in Metalab document, used when no space document file is configured
+func defaultSpaceDocument() *SpaceAPIv15 {
+	return &SpaceAPIv15{
+		APICompatibility: []string{"14", "15"},
+		Space:            "Metalab",
+		Logo:             "https://metalab.at/static/images/logo.png",
+		URL:              "https://metalab.at",
+		Location: &Location{
+			Address:     "Rathausstraße 6, 1010 Vienna, Austria",
+			Lat:         48.2093723,
+			Lon:         16.356099,
+			Timezone:    "Europe/Vienna",
+			CountryCode: "AT",
+		},
+		SpaceFed: &SpaceFed{
+			SpaceNet:  false,
+			SpaceSAML: false,
+		},
+		State: &State{
+			Open: nil,
+		},
+		Contact: &Contact{
+			Phone:     "+43 720 002323",
+			Email:     "core@metalab.at",
+			IssueMail: "core@metalab.at",
+			ML:        "metalab@lists.metalab.at",
+			Mastodon:  "@metalab@chaos.social",
+			SIP:       "6382",
+		},
+		Links: []Link{
+			{
+				Name: "Metalab Wiki",
+				URL:  "https://metalab.at/wiki",
+			},
 		},
-	},
-	Feeds: &Feeds{
-		Calendar: &Feed{
-			Type: "rss",
-			URL:  "https://metalab.at/feeds/events/",
+		Feeds: &Feeds{
+			Calendar: &Feed{
+				Type: "rss",
+				URL:  "https://metalab.at/feeds/events/",
+			},
 		},
-	},
-	Projects: []string{
-		"https://github.com/metalab",
-		"https://metalab.at/wiki/Projekte_Neu",
-		"https://metalab.at/project",
-	},
+		Projects: []string{
+			"https://github.com/metalab",
+			"https://metalab.at/wiki/Projekte_Neu",
+			"https://metalab.at/project",
+		},
+	}
 }
 
+// LabStatusAPIResponse is the response of the upstream lab state api,
+// the status itself is looked up via the configured json pointer
 type LabStatusAPIResponse struct {
-	State           string `json:"state"`
 	LastChangedUnix int64  `json:"last_changed"`
 	LastUpdatedUnix int64  `json:"last_updated"`
+	Message         string `json:"message"`
+	TriggerPerson   string `json:"trigger_person"`
 }
 
-var previousStatus = "unknown"
-var lastChangedUnix = int64(0)
-var cachedSpaceApiResponse = spaceApiData
-
 func Pointer[T any](d T) *T {
 	return &d
 }
 
-func handleSpaceApiV15(w http.ResponseWriter, r *http.Request) {
-	labState, labStateLastChange, labStateError := fetchLabState()
-	if labStateError != nil {
-		//http.Error(w, labStateError.Error(), http.StatusInternalServerError)
-		//spaceApiData.State.Open = nil
-		fmt.Printf("lab state error not nil, returning cached data\n")
-		spaceApiData = cachedSpaceApiResponse
-	} else {
-		spaceApiData.State.Open = labState
-		if labStateLastChange != nil {
-			spaceApiData.State.LastChange = *labStateLastChange
+func (s *Server) handleSpaceApiV15(w http.ResponseWriter, r *http.Request) {
+	s.setCacheControl(w)
+	//the document includes sensors, events and projects, which change independently of the last state change,
+	//so Last-Modified is only informational and the document is revalidated by its ETag
+	doc := s.currentSpaceApiDocument(w, r)
+	setLastModified(w, doc.State.LastChange)
+	s.filterFields(r, doc)
+	s.writeNegotiated(w, r, "spaceapi", doc)
+}
+
+// currentSpaceApiDocument returns a per-request copy of the document with the current lab state filled in
+func (s *Server) currentSpaceApiDocument(w http.ResponseWriter, r *http.Request) *SpaceAPIv15 {
+	//the shared document must not be mutated by concurrent requests
+	doc := *s.doc
+	//the state api and the other upstreams share a single deadline, which stays below the request timeout
+	ctx, cancel := s.upstreamContext(r)
+	defer cancel()
+	doc.State = s.currentState(w, r)
+	doc.Sensors = s.currentSensors(ctx, s.doc.Sensors)
+	if s.doc.Contact != nil {
+		contact := *s.doc.Contact
+		contact.Keymasters = s.currentKeymasters(ctx, s.doc.Contact.Keymasters)
+		doc.Contact = &contact
+	}
+	//the radio show is only advertised while it is on air
+	if s.doc.RadioShow != nil {
+		onAir, err := radioShowOnAir(s.doc.RadioShow, s.clock.Now(), s.spaceLocation(), s.config.RadioShowRRule)
+		if err != nil {
+			s.logger.Warn("error while checking whether the radio show is on air", "error", err)
+		}
+		if !onAir {
+			doc.RadioShow = nil
+		}
+	}
+	if s.config.EventsCalendarURL != "" {
+		if events, ok := s.eventsCache.getOrFetch(ctx, s.fetchEvents); ok && len(events) > 0 {
+			doc.Events = events
+		}
+	}
+	if s.config.MastodonAccount != "" {
+		if events, ok := s.mastodonCache.getOrFetch(ctx, s.fetchMastodonEvents); ok && len(events) > 0 {
+			//the cached slices must not be appended to
+			doc.Events = slices.Concat(doc.Events, events)
+		}
+	}
+	//the static projects are kept if the repositories can't be fetched
+	if s.config.GitHubOrg != "" {
+		if repositories, ok := s.gitHubCache.getOrFetch(ctx, s.fetchGitHubProjects); ok {
+			doc.Projects = expandProjects(s.doc.Projects, "https://github.com/"+s.config.GitHubOrg, repositories)
 		}
-		cachedSpaceApiResponse = spaceApiData
 	}
-	p, _ := json.MarshalIndent(spaceApiData, "", "    ")
+	return &doc
+}
+
+// writeJSON marshals v and writes it as the response, honoring conditional requests
+func (s *Server) writeJSON(w http.ResponseWriter, r *http.Request, v any) {
+	s.writeJSONAs(w, r, "application/json", v)
+}
+
+// writeJSONAs writes v as JSON with the given content type, e.g. for JSON based formats like JSON-LD
+func (s *Server) writeJSONAs(w http.ResponseWriter, r *http.Request, contentType string, v any) {
+	var p []byte
+	var err error
+	if wantsPretty(r) {
+		p, err = json.MarshalIndent(v, "", "  ")
+	} else {
+		p, err = json.Marshal(v)
+	}
+	if err != nil {
+		s.logger.Error("error while marshalling response", "path", r.URL.Path, "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", contentType)
+	if checkNotModified(w, r, p) {
+		return
+	}
 	w.Write(p)
 }
 
-func fetchLabState() (*bool, *int64, error) {
-	client := &http.Client{Timeout: 5 * time.Second}
+// wantsPretty reports whether the client asked for indented output with ?pretty, ?pretty=1 or ?pretty=true
+func wantsPretty(r *http.Request) bool {
+	query := r.URL.Query()
+	if !query.Has("pretty") {
+		return false
+	}
+	value := query.Get("pretty")
+	pretty, err := strconv.ParseBool(value)
+	return value == "" || (err == nil && pretty)
+}
+
+// fetchLabStateFrom fetches the lab state from the state api at url, retrying transient failures with exponential backoff,
+// cancelling ctx aborts the request
+func (f *HTTPStateFetcher) fetchLabStateFrom(ctx context.Context, url string) (LabState, error) {
+	ctx, cancel := context.WithTimeout(ctx, f.config.StateFetchDeadline)
+	defer cancel()
+
+	//serve the cached state while the state api asked us to back off
+	if until, ok := f.throttle.get(url, f.clock.Now()); ok {
+		return LabState{}, &throttledError{Until: until}
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= f.config.StateFetchAttempts; attempt++ {
+		if attempt > 1 {
+			delay := retryDelay(attempt-1, f.config.StateFetchRetryDelay, f.config.StateFetchRetryJitter)
+			f.logger.Warn("retrying state api request", "url", url, "attempt", attempt, "delay", delay, "error", lastErr)
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return LabState{}, fmt.Errorf("%w (last error: %v)", ctx.Err(), lastErr)
+			}
+		}
+
+		state, err := f.fetchLabStateOnce(ctx, url)
+		if err == nil {
+			return state, nil
+		}
+		lastErr = err
+		if !isRetryable(ctx, err) {
+			break
+		}
+	}
+	return LabState{}, lastErr
+}
 
-	req, err := http.NewRequest("GET", "https://eingang.metalab.at/status.json", nil)
+// fetchLabStateOnce performs a single request to the state api, bounded by the per-attempt timeout
+func (f *HTTPStateFetcher) fetchLabStateOnce(ctx context.Context, url string) (LabState, error) {
+	ctx, cancel := context.WithTimeout(ctx, f.config.StateFetchTimeout)
+	defer cancel()
 
-	//req, err := http.NewRequest("GET", "http://localhost:3333/lab", nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		fmt.Printf("error while building rest request to state api: %v\n", err)
-		return nil, nil, err
+		f.logger.Error("error while building rest request to state api", "url", url, "error", err)
+		return LabState{}, err
 	}
 
 	//set required header
 	req.Header.Set("Content-Type", "application/json")
 
 	//actually send the request
-	resp, requestErr := client.Do(req)
+	resp, requestErr := f.client.Do(req)
 	if requestErr != nil {
-		fmt.Printf("error while sending request to state api: %v\n", requestErr)
-		return nil, nil, requestErr
+		f.logger.Error("error while sending request to state api", "url", url, "error", requestErr)
+		return LabState{}, requestErr
 	}
 
 	//close the request and read the body
 	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		statusErr := &statusError{StatusCode: resp.StatusCode}
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+			if until, ok := parseRetryAfter(resp.Header.Get("Retry-After"), f.clock.Now()); ok {
+				statusErr.RetryAfter = until
+				f.throttle.set(url, until)
+				f.logger.Warn("state api asked to back off", "url", url, "until", until)
+			}
+		}
+		f.logger.Error("state api returned unexpected status", "url", url, "status", resp.StatusCode)
+		return LabState{}, statusErr
+	}
 	body, readErr := io.ReadAll(resp.Body)
 	if readErr != nil {
-		fmt.Printf("error while reading response body from state api: %v\n", readErr)
-		return nil, nil, readErr
+		f.logger.Error("error while reading response body from state api", "url", url, "status", resp.StatusCode, "error", readErr)
+		return LabState{}, readErr
 	}
 
-	/*var r LabStatusAPIResponse
-	json.Unmarshal(body, &r)
-	if r.State == "on" {
-		return Pointer(true), &r.LastChangedUnix, nil
-	} else if r.State == "off" {
-		return Pointer(false), &r.LastChangedUnix, nil
-	} else {
-		return nil, nil, fmt.Errorf("unknown state: %s", r.State)
-	}*/
-
-	type LabStatus struct {
-		Status string `json:"status"`
+	state, err := parseLabState(f.config, body)
+	if err != nil {
+		f.logger.Error("error while parsing response body from state api", "url", url, "status", resp.StatusCode, "error", err)
+		return LabState{}, err
 	}
+	return state, nil
+}
 
-	var r LabStatus
+// parseLabState parses a state api response, the status is looked up at the configured path
+func parseLabState(config Config, body []byte) (LabState, error) {
+	var r LabStatusAPIResponse
+	var doc any
 	jsonErr := json.Unmarshal(body, &r)
+	if jsonErr == nil {
+		jsonErr = json.Unmarshal(body, &doc)
+	}
 	if jsonErr != nil {
-		return nil, nil, jsonErr
+		return LabState{}, fmt.Errorf("error while unmarshalling state api response %q: %w", truncate(string(body), 100), jsonErr)
+	}
+
+	state := LabState{Message: r.Message, TriggerPerson: r.TriggerPerson}
+	//only report a last change if the state api provides one
+	if r.LastChangedUnix != 0 {
+		state.LastChange = Pointer(r.LastChangedUnix)
+	}
+
+	status, ok := lookupJSONPointer(doc, config.StateStatusPath)
+	//older versions of the state api report "state" as on/off instead of "status" as open/closed
+	if !ok && config.StateStatusPath == "/status" {
+		status, ok = lookupJSONPointer(doc, "/state")
+	}
+	if !ok {
+		return LabState{}, fmt.Errorf("state api response %q has no status at %s", truncate(string(body), 100), config.StateStatusPath)
+	}
+	open, err := parseStatus(config, status)
+	if err != nil {
+		return LabState{}, err
 	}
+	state.Open = Pointer(open)
+	return state, nil
+}
 
-	if r.Status == "open" {
-		if previousStatus != "open" {
-			previousStatus = r.Status
-			lastChangedUnix = time.Now().Unix()
+// parseStatus maps a status of the state api to the open state, firmwares report it as a JSON bool,
+// a number (1 or 0) or a string interchangeably
+func parseStatus(config Config, status any) (bool, error) {
+	switch v := status.(type) {
+	case bool:
+		return v, nil
+	case float64:
+		if v == 1 || v == 0 {
+			return v == 1, nil
 		}
-		return Pointer(true), &lastChangedUnix, nil
-	} else if r.Status == "closed" {
-		if previousStatus != "closed" {
-			previousStatus = r.Status
-			lastChangedUnix = time.Now().Unix()
+	case string:
+		open, err := parseStateToken(config, v)
+		if err == nil {
+			return open, nil
 		}
-		return Pointer(false), &lastChangedUnix, nil
-	} else {
-		return nil, nil, fmt.Errorf("unknown state: %s", r.Status)
+		//strings like "true" or "0" are booleans in disguise
+		if b, boolErr := strconv.ParseBool(strings.TrimSpace(v)); boolErr == nil {
+			return b, nil
+		}
+		return false, err
 	}
+	return false, fmt.Errorf("unknown state: %v", status)
+}
 
+// parseStateToken maps a status reported by the state api to the open state using the configured tokens, ignoring case
+func parseStateToken(config Config, token string) (bool, error) {
+	if slices.ContainsFunc(config.StateOpenTokens, func(t string) bool { return strings.EqualFold(t, token) }) {
+		return true, nil
+	}
+	if slices.ContainsFunc(config.StateClosedTokens, func(t string) bool { return strings.EqualFold(t, token) }) {
+		return false, nil
+	}
+	return false, fmt.Errorf("unknown state: %s", token)
+}
+
+// truncate shortens s to at most n bytes, marking the cut with an ellipsis
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
 }
 
 func main() {
-	http.HandleFunc("/v14", handleSpaceApiV15) //v14 is also compatible with v15
-	http.HandleFunc("/v15", handleSpaceApiV15)
+	checkConfig := flag.Bool("check-config", false, "validate the configuration and the space document, then exit without starting the server")
+	flag.Parse()
+
+	config, err := LoadConfig()
+	if err != nil {
+		slog.Error("invalid configuration", "error", err)
+		os.Exit(1)
+	}
+
+	logger := newLogger(config.LogLevel, config.LogFormat)
+	slog.SetDefault(logger)
+
+	server, err := NewServer(WithConfig(config), WithLogger(logger))
+	if err != nil {
+		slog.Error("error while creating server", "error", err)
+		os.Exit(1)
+	}
+	if *checkConfig {
+		//creating the server runs every startup validation, but nothing is bound or started yet
+		fmt.Printf("configuration is valid, %s with %d state sources, listening on %s\n",
+			server.doc.Space, len(config.StateAPIURLs), config.ListenAddr)
+		return
+	}
+	prometheus.MustRegister(sensorCollector{server})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	fmt.Println("Server starting on port 3334...")
-	if err := http.ListenAndServe(":3334", nil); err != nil {
-		log.Fatal(err)
+	if err := server.Run(ctx); err != nil {
+		slog.Error("server failed", "error", err)
+		os.Exit(1)
 	}
 }
 
 // SpaceAPIv15 represents the main SpaceAPI v15 structure
 type SpaceAPIv15 struct {
-	APICompatibility []string   `json:"api_compatibility"`
-	Space            string     `json:"space"`
-	Logo             string     `json:"logo,omitempty"`
-	URL              string     `json:"url,omitempty"`
-	Location         *Location  `json:"location,omitempty"`
-	SpaceFed         *SpaceFed  `json:"spacefed,omitempty"`
-	Cam              []string   `json:"cam,omitempty"`
-	State            *State     `json:"state,omitempty"`
-	Events           []Event    `json:"events,omitempty"`
-	Contact          *Contact   `json:"contact,omitempty"`
-	Sensors          *Sensors   `json:"sensors,omitempty"`
-	Feeds            *Feeds     `json:"feeds,omitempty"`
-	Links            []Link     `json:"links,omitempty"`
-	Cache            *Cache     `json:"cache,omitempty"`
-	Projects         []string   `json:"projects,omitempty"`
-	RadioShow        *RadioShow `json:"radio_show,omitempty"`
+	APICompatibility []string   `json:"api_compatibility" xml:"api_compatibility"`
+	Space            string     `json:"space" xml:"space"`
+	Logo             string     `json:"logo,omitempty" xml:"logo,omitempty"`
+	URL              string     `json:"url,omitempty" xml:"url,omitempty"`
+	Location         *Location  `json:"location,omitempty" xml:"location,omitempty"`
+	SpaceFed         *SpaceFed  `json:"spacefed,omitempty" xml:"spacefed,omitempty"`
+	Cam              []string   `json:"cam,omitempty" xml:"cam,omitempty"`
+	State            *State     `json:"state,omitempty" xml:"state,omitempty"`
+	Events           []Event    `json:"events,omitempty" xml:"events,omitempty"`
+	Contact          *Contact   `json:"contact,omitempty" xml:"contact,omitempty"`
+	Sensors          *Sensors   `json:"sensors,omitempty" xml:"sensors,omitempty"`
+	Feeds            *Feeds     `json:"feeds,omitempty" xml:"feeds,omitempty"`
+	Links            []Link     `json:"links,omitempty" xml:"links,omitempty"`
+	Cache            *Cache     `json:"cache,omitempty" xml:"cache,omitempty"`
+	Projects         []string   `json:"projects,omitempty" xml:"projects,omitempty"`
+	RadioShow        *RadioShow `json:"radio_show,omitempty" xml:"radio_show,omitempty"`
+
+	Extensions map[string]json.RawMessage `json:"-" xml:"-"` // ext_ prefixed fields added to the JSON document as is
 }
 
 // Location represents the physical location of the space
 type Location struct {
-	Address     string  `json:"address,omitempty"`
-	Lat         float64 `json:"lat,omitempty"`
-	Lon         float64 `json:"lon,omitempty"`
-	Timezone    string  `json:"timezone,omitempty"`
-	CountryCode string  `json:"country_code,omitempty"`
-	Hint        string  `json:"hint,omitempty"`
-	Areas       []Area  `json:"areas,omitempty"`
+	Address     string  `json:"address,omitempty" xml:"address,omitempty"`
+	Lat         float64 `json:"lat,omitempty" xml:"lat,omitempty"`
+	Lon         float64 `json:"lon,omitempty" xml:"lon,omitempty"`
+	Timezone    string  `json:"timezone,omitempty" xml:"timezone,omitempty"`
+	CountryCode string  `json:"country_code,omitempty" xml:"country_code,omitempty"`
+	Hint        string  `json:"hint,omitempty" xml:"hint,omitempty"`
+	Areas       []Area  `json:"areas,omitempty" xml:"areas,omitempty"`
 }
 
 // Area represents a physical area within the space
 type Area struct {
-	Name         string  `json:"name,omitempty"`
-	Description  string  `json:"description,omitempty"`
-	SquareMeters float64 `json:"square_meters"` // Required
+	Name         string  `json:"name,omitempty" xml:"name,omitempty"`
+	Description  string  `json:"description,omitempty" xml:"description,omitempty"`
+	SquareMeters float64 `json:"square_meters" xml:"square_meters"` // Required
 }
 
 // SpaceFed represents SpaceFED authentication information
 type SpaceFed struct {
-	SpaceNet  bool `json:"spacenet"`  // Required
-	SpaceSAML bool `json:"spacesaml"` // Required
+	SpaceNet  bool `json:"spacenet" xml:"spacenet"`   // Required
+	SpaceSAML bool `json:"spacesaml" xml:"spacesaml"` // Required
 }
 
-// State represents the current state of the space
+// State represents the current state of the space, Open is nil while the state is unknown, which leaves the
+// field out as the v14 and v15 schemas expect, only the v13 layout publishes it as "open": null
 type State struct {
-	Open          *bool      `json:"open"`
-	LastChange    int64      `json:"lastchange,omitempty"`
-	TriggerPerson string     `json:"trigger_person,omitempty"`
-	Message       string     `json:"message,omitempty"`
-	Icon          *StateIcon `json:"icon,omitempty"`
+	Open          *bool      `json:"open,omitempty" xml:"open,omitempty"`
+	LastChange    int64      `json:"lastchange,omitempty" xml:"lastchange,omitempty"`
+	TriggerPerson string     `json:"trigger_person,omitempty" xml:"trigger_person,omitempty"`
+	Message       string     `json:"message,omitempty" xml:"message,omitempty"`
+	Icon          *StateIcon `json:"icon,omitempty" xml:"icon,omitempty"`
 }
 
 // StateIcon represents the URLs for state icons
 type StateIcon struct {
-	Open   string `json:"open"`   // Required
-	Closed string `json:"closed"` // Required
+	Open   string `json:"open" xml:"open"`     // Required
+	Closed string `json:"closed" xml:"closed"` // Required
 }
 
 // Event represents an event in the space
 type Event struct {
-	Name      string `json:"name"`      // Required
-	Type      string `json:"type"`      // Required
-	Timestamp int64  `json:"timestamp"` // Required
-	Extra     string `json:"extra,omitempty"`
+	Name      string `json:"name" xml:"name"`           // Required
+	Type      string `json:"type" xml:"type"`           // Required
+	Timestamp int64  `json:"timestamp" xml:"timestamp"` // Required
+	Extra     string `json:"extra,omitempty" xml:"extra,omitempty"`
 }
 
 // Contact contains various contact methods for the space
 type Contact struct {
-	Phone      string      `json:"phone,omitempty"`
-	SIP        string      `json:"sip,omitempty"`
-	Keymasters []Keymaster `json:"keymasters,omitempty"`
-	IRC        string      `json:"irc,omitempty"`
-	Twitter    string      `json:"twitter,omitempty"`
-	Mastodon   string      `json:"mastodon,omitempty"`
-	Facebook   string      `json:"facebook,omitempty"`
-	Identica   string      `json:"identica,omitempty"`
-	Foursquare string      `json:"foursquare,omitempty"`
-	Email      string      `json:"email,omitempty"`
-	ML         string      `json:"ml,omitempty"`
-	XMPP       string      `json:"xmpp,omitempty"`
-	IssueMail  string      `json:"issue_mail,omitempty"`
-	Gopher     string      `json:"gopher,omitempty"`
-	Matrix     string      `json:"matrix,omitempty"`
-	Mumble     string      `json:"mumble,omitempty"`
+	Phone      string      `json:"phone,omitempty" xml:"phone,omitempty"`
+	SIP        string      `json:"sip,omitempty" xml:"sip,omitempty"`
+	Keymasters []Keymaster `json:"keymasters,omitempty" xml:"keymasters,omitempty"`
+	IRC        string      `json:"irc,omitempty" xml:"irc,omitempty"`
+	Twitter    string      `json:"twitter,omitempty" xml:"twitter,omitempty"`
+	Mastodon   string      `json:"mastodon,omitempty" xml:"mastodon,omitempty"`
+	Facebook   string      `json:"facebook,omitempty" xml:"facebook,omitempty"`
+	Identica   string      `json:"identica,omitempty" xml:"identica,omitempty"`
+	Foursquare string      `json:"foursquare,omitempty" xml:"foursquare,omitempty"`
+	Email      string      `json:"email,omitempty" xml:"email,omitempty"`
+	ML         string      `json:"ml,omitempty" xml:"ml,omitempty"`
+	XMPP       string      `json:"xmpp,omitempty" xml:"xmpp,omitempty"`
+	IssueMail  string      `json:"issue_mail,omitempty" xml:"issue_mail,omitempty"`
+	Gopher     string      `json:"gopher,omitempty" xml:"gopher,omitempty"`
+	Matrix     string      `json:"matrix,omitempty" xml:"matrix,omitempty"`
+	Mumble     string      `json:"mumble,omitempty" xml:"mumble,omitempty"`
 }
 
 // Keymaster represents a person who has access to the space
 type Keymaster struct {
-	Name     string `json:"name,omitempty"`
-	IRCNick  string `json:"irc_nick,omitempty"`
-	Phone    string `json:"phone,omitempty"`
-	Email    string `json:"email,omitempty"`
-	Twitter  string `json:"twitter,omitempty"`
-	XMPP     string `json:"xmpp,omitempty"`
-	Mastodon string `json:"mastodon,omitempty"`
-	Matrix   string `json:"matrix,omitempty"`
+	Name     string `json:"name,omitempty" xml:"name,omitempty"`
+	IRCNick  string `json:"irc_nick,omitempty" xml:"irc_nick,omitempty"`
+	Phone    string `json:"phone,omitempty" xml:"phone,omitempty"`
+	Email    string `json:"email,omitempty" xml:"email,omitempty"`
+	Twitter  string `json:"twitter,omitempty" xml:"twitter,omitempty"`
+	XMPP     string `json:"xmpp,omitempty" xml:"xmpp,omitempty"`
+	Mastodon string `json:"mastodon,omitempty" xml:"mastodon,omitempty"`
+	Matrix   string `json:"matrix,omitempty" xml:"matrix,omitempty"`
 }
 
 // Sensors represent various sensor data in the space
 type Sensors struct {
-	Temperature    []TempSensor      `json:"temperature,omitempty"`
-	CarbonDioxide  []CO2Sensor       `json:"carbondioxide,omitempty"`
-	DoorLocked     []DoorSensor      `json:"door_locked,omitempty"`
-	Barometer      []BarometerSensor `json:"barometer,omitempty"`
-	Radiation      *RadiationSensors `json:"radiation,omitempty"`
-	Humidity       []HumiditySensor  `json:"humidity,omitempty"`
-	BeverageSupply []BeverageSensor  `json:"beverage_supply,omitempty"`
+	Temperature    []TempSensor      `json:"temperature,omitempty" xml:"temperature,omitempty"`
+	CarbonDioxide  []CO2Sensor       `json:"carbondioxide,omitempty" xml:"carbondioxide,omitempty"`
+	DoorLocked     []DoorSensor      `json:"door_locked,omitempty" xml:"door_locked,omitempty"`
+	Barometer      []BarometerSensor `json:"barometer,omitempty" xml:"barometer,omitempty"`
+	Radiation      *RadiationSensors `json:"radiation,omitempty" xml:"radiation,omitempty"`
+	Humidity       []HumiditySensor  `json:"humidity,omitempty" xml:"humidity,omitempty"`
+	BeverageSupply []BeverageSensor  `json:"beverage_supply,omitempty" xml:"beverage_supply,omitempty"`
 }
 
 // BaseSensor contains common sensor fields
 type BaseSensor struct {
-	Location    string `json:"location"` // Required
-	Name        string `json:"name,omitempty"`
-	Description string `json:"description,omitempty"`
-	LastChange  int64  `json:"lastchange,omitempty"`
+	Location    string `json:"location" xml:"location"` // Required
+	Name        string `json:"name,omitempty" xml:"name,omitempty"`
+	Description string `json:"description,omitempty" xml:"description,omitempty"`
+	LastChange  int64  `json:"lastchange,omitempty" xml:"lastchange,omitempty"`
 }
 
 // TempSensor represents a temperature sensor
 type TempSensor struct {
 	BaseSensor
-	Value float64 `json:"value"` // Required
-	Unit  string  `json:"unit"`  // Required
+	Value float64 `json:"value" xml:"value"` // Required
+	Unit  string  `json:"unit" xml:"unit"`   // Required
 }
 
 // CO2Sensor represents a CO2 sensor
 type CO2Sensor struct {
 	BaseSensor
-	Value float64 `json:"value"` // Required
-	Unit  string  `json:"unit"`  // Required
+	Value float64 `json:"value" xml:"value"` // Required
+	Unit  string  `json:"unit" xml:"unit"`   // Required
 }
 
 // DoorSensor represents a door lock sensor
 type DoorSensor struct {
 	BaseSensor
-	Value bool `json:"value"` // Required
+	Value bool `json:"value" xml:"value"` // Required
 }
 
 // BarometerSensor represents a barometer sensor
 type BarometerSensor struct {
 	BaseSensor
-	Value float64 `json:"value"` // Required
-	Unit  string  `json:"unit"`  // Required
+	Value float64 `json:"value" xml:"value"` // Required
+	Unit  string  `json:"unit" xml:"unit"`   // Required
 }
 
 // RadiationSensors represents all radiation sensor types
 type RadiationSensors struct {
-	Alpha     []RadiationSensor `json:"alpha,omitempty"`
-	Beta      []RadiationSensor `json:"beta,omitempty"`
-	Gamma     []RadiationSensor `json:"gamma,omitempty"`
-	BetaGamma []RadiationSensor `json:"beta_gamma,omitempty"`
+	Alpha     []RadiationSensor `json:"alpha,omitempty" xml:"alpha,omitempty"`
+	Beta      []RadiationSensor `json:"beta,omitempty" xml:"beta,omitempty"`
+	Gamma     []RadiationSensor `json:"gamma,omitempty" xml:"gamma,omitempty"`
+	BetaGamma []RadiationSensor `json:"beta_gamma,omitempty" xml:"beta_gamma,omitempty"`
 }
 
 // RadiationSensor represents a radiation sensor
 type RadiationSensor struct {
 	BaseSensor
-	Value            float64 `json:"value"` // Required
-	Unit             string  `json:"unit"`  // Required
-	DeadTime         float64 `json:"dead_time,omitempty"`
-	ConversionFactor float64 `json:"conversion_factor,omitempty"`
+	Value            float64 `json:"value" xml:"value"` // Required
+	Unit             string  `json:"unit" xml:"unit"`   // Required
+	DeadTime         float64 `json:"dead_time,omitempty" xml:"dead_time,omitempty"`
+	ConversionFactor float64 `json:"conversion_factor,omitempty" xml:"conversion_factor,omitempty"`
 }
 
 // HumiditySensor represents a humidity sensor
 type HumiditySensor struct {
 	BaseSensor
-	Value float64 `json:"value"` // Required
-	Unit  string  `json:"unit"`  // Required
+	Value float64 `json:"value" xml:"value"` // Required
+	Unit  string  `json:"unit" xml:"unit"`   // Required
 }
 
 // BeverageSensor represents a beverage supply sensor
 type BeverageSensor struct {
 	BaseSensor
-	Value float64 `json:"value"` // Required
-	Unit  string  `json:"unit"`  // Required
+	Value float64 `json:"value" xml:"value"` // Required
+	Unit  string  `json:"unit" xml:"unit"`   // Required
 }
 
 // Feeds represents various feeds available for the space
 type Feeds struct {
-	Blog     *Feed `json:"blog,omitempty"`
-	Wiki     *Feed `json:"wiki,omitempty"`
-	Calendar *Feed `json:"calendar,omitempty"`
-	Flickr   *Feed `json:"flickr,omitempty"`
+	Blog     *Feed `json:"blog,omitempty" xml:"blog,omitempty"`
+	Wiki     *Feed `json:"wiki,omitempty" xml:"wiki,omitempty"`
+	Calendar *Feed `json:"calendar,omitempty" xml:"calendar,omitempty"`
+	Flickr   *Feed `json:"flickr,omitempty" xml:"flickr,omitempty"`
 }
 
 // Feed represents a generic feed URL with type
 type Feed struct {
-	Type string `json:"type,omitempty"` // Type of the feed (e.g., rss, ical, atom)
-	URL  string `json:"url"`            // Required
+	Type string `json:"type,omitempty" xml:"type,omitempty"` // Type of the feed (e.g., rss, ical, atom)
+	URL  string `json:"url" xml:"url"`                       // Required
 }
 
 // Link represents external links related to the space
 type Link struct {
-	Name        string `json:"name"` // Required
-	Description string `json:"description,omitempty"`
-	URL         string `json:"url"` // Required
+	Name        string `json:"name" xml:"name"` // Required
+	Description string `json:"description,omitempty" xml:"description,omitempty"`
+	URL         string `json:"url" xml:"url"` // Required
 }
 
 // Cache represents caching information
 type Cache struct {
-	Schedule string `json:"schedule"` // Required - cron-like schedule string
+	Schedule string `json:"schedule" xml:"schedule"` // Required - cron-like schedule string
 }
 
 // RadioShow represents information about the space's radio show
 type RadioShow struct {
-	Name        string   `json:"name"`                 // Required
-	URL         string   `json:"url"`                  // Required
-	Type        string   `json:"type"`                 // Required
-	StartTime   string   `json:"start_time,omitempty"` // ISO 8601 formatted time
-	EndTime     string   `json:"end_time,omitempty"`   // ISO 8601 formatted time
-	StreamURL   string   `json:"stream_url,omitempty"`
-	StreamType  string   `json:"stream_type,omitempty"`
-	Description string   `json:"description,omitempty"`
-	Tags        []string `json:"tags,omitempty"`
+	Name        string   `json:"name" xml:"name"`                                 // Required
+	URL         string   `json:"url" xml:"url"`                                   // Required
+	Type        string   `json:"type" xml:"type"`                                 // Required
+	StartTime   string   `json:"start_time,omitempty" xml:"start_time,omitempty"` // ISO 8601 formatted time
+	EndTime     string   `json:"end_time,omitempty" xml:"end_time,omitempty"`     // ISO 8601 formatted time
+	StreamURL   string   `json:"stream_url,omitempty" xml:"stream_url,omitempty"`
+	StreamType  string   `json:"stream_type,omitempty" xml:"stream_type,omitempty"`
+	Description string   `json:"description,omitempty" xml:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty" xml:"tags,omitempty"`
 }
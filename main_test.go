@@ -0,0 +1,182 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseLabStateInvalidJSON(t *testing.T) {
+	_, err := parseLabState(testConfig(t), []byte(`{"status": "open"`))
+	if err == nil || !strings.Contains(err.Error(), "unmarshalling") {
+		t.Errorf("parseLabState() = %v, want an unmarshalling error", err)
+	}
+	if err != nil && !strings.Contains(err.Error(), `{\"status\": \"open\"`) {
+		t.Errorf("parseLabState() = %v, want the error to quote the body", err)
+	}
+}
+
+func TestParseLabStateLastChange(t *testing.T) {
+	state, err := parseLabState(testConfig(t), []byte(`{"status":"open","last_changed":1700000000,"last_updated":1700000600}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.LastChange == nil || *state.LastChange != 1700000000 {
+		t.Errorf("lastchange = %v, want 1700000000", state.LastChange)
+	}
+
+	state, err = parseLabState(testConfig(t), []byte(`{"status":"open"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.LastChange != nil {
+		t.Errorf("lastchange = %d, want none", *state.LastChange)
+	}
+}
+
+func TestParseLabStateMessage(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{`{"status":"open","message":"closing early today"}`, "closing early today"},
+		{`{"status":"open"}`, ""},
+	}
+	for _, test := range tests {
+		state, err := parseLabState(testConfig(t), []byte(test.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state.Message != test.want {
+			t.Errorf("message of %s = %q, want %q", test.body, state.Message, test.want)
+		}
+	}
+}
+
+func TestPrettyJSON(t *testing.T) {
+	s, _ := newTestServer(t, testConfig(t), staticState(LabState{Open: Pointer(true)}))
+	tests := []struct {
+		target string
+		pretty bool
+	}{
+		{"/v15", false},
+		{"/v15?pretty", true},
+		{"/v15?pretty=1", true},
+		{"/v15?pretty=true", true},
+		{"/v15?pretty=false", false},
+		{"/v13?pretty", true},
+	}
+	for _, test := range tests {
+		body := serve(s, "GET", test.target, nil).Body.String()
+		indented := strings.Contains(body, "\n  \"space\": \"Metalab\"")
+		if indented != test.pretty || (!test.pretty && strings.Contains(body, "\n")) {
+			t.Errorf("%s: body = %q, want indented %v", test.target, truncate(body, 80), test.pretty)
+		}
+	}
+}
+
+func TestParseStateToken(t *testing.T) {
+	config := testConfig(t)
+	config.StateOpenTokens = []string{"offen", "unlocked"}
+	config.StateClosedTokens = []string{"geschlossen", "locked"}
+	tests := []struct {
+		token string
+		want  *bool
+	}{
+		{"offen", Pointer(true)},
+		{"OFFEN", Pointer(true)},
+		{"Unlocked", Pointer(true)},
+		{"geschlossen", Pointer(false)},
+		{"LOCKED", Pointer(false)},
+		{"open", nil},
+		{"closed", nil},
+		{"", nil},
+	}
+	for _, test := range tests {
+		open, err := parseStateToken(config, test.token)
+		if test.want == nil && err == nil {
+			t.Errorf("parseStateToken(%q) = %v, want an unknown state error", test.token, open)
+		}
+		if test.want != nil && (err != nil || open != *test.want) {
+			t.Errorf("parseStateToken(%q) = %v, %v, want %v", test.token, open, err, *test.want)
+		}
+	}
+}
+
+func TestParseStateTokenDefaults(t *testing.T) {
+	config := testConfig(t)
+	for token, want := range map[string]bool{"open": true, "Open": true, "on": true, "closed": false, "CLOSED": false, "off": false} {
+		if open, err := parseStateToken(config, token); err != nil || open != want {
+			t.Errorf("parseStateToken(%q) = %v, %v, want %v", token, open, err, want)
+		}
+	}
+	if _, err := parseStateToken(config, "ajar"); err == nil || !strings.Contains(err.Error(), "unknown state") {
+		t.Errorf("parseStateToken(ajar) = %v, want an unknown state error", err)
+	}
+}
+
+func TestParseLabStateStatusPath(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		body string
+		want *bool
+	}{
+		{"flat", "/status", `{"status":"open"}`, Pointer(true)},
+		{"flat legacy state", "/status", `{"state":"off"}`, Pointer(false)},
+		{"nested", "/door/state", `{"door":{"state":"closed"}}`, Pointer(false)},
+		{"array", "/doors/1/state", `{"doors":[{"state":"closed"},{"state":"open"}]}`, Pointer(true)},
+		{"escaped", "/door~1main", `{"door/main":"open"}`, Pointer(true)},
+		{"missing", "/door/state", `{"status":"open"}`, nil},
+		{"not an object", "/door/state", `{"door":"open"}`, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := testConfig(t)
+			config.StateStatusPath = test.path
+			state, err := parseLabState(config, []byte(test.body))
+			if test.want == nil && err == nil {
+				t.Errorf("parseLabState() = %s, want an error", formatState(state.Open))
+			}
+			if test.want != nil && (err != nil || !equalState(state.Open, test.want)) {
+				t.Errorf("parseLabState() = %s, %v, want %s", formatState(state.Open), err, formatState(test.want))
+			}
+		})
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	tests := []struct {
+		status string
+		want   *bool
+	}{
+		{`true`, Pointer(true)},
+		{`false`, Pointer(false)},
+		{`1`, Pointer(true)},
+		{`0`, Pointer(false)},
+		{`1.0`, Pointer(true)},
+		{`2`, nil},
+		{`-1`, nil},
+		{`"open"`, Pointer(true)},
+		{`"closed"`, Pointer(false)},
+		{`"true"`, Pointer(true)},
+		{`"FALSE"`, Pointer(false)},
+		{`"1"`, Pointer(true)},
+		{`" 0 "`, Pointer(false)},
+		{`"maybe"`, nil},
+		{`null`, nil},
+		{`{"open":true}`, nil},
+		{`[true]`, nil},
+	}
+	for _, test := range tests {
+		state, err := parseLabState(testConfig(t), []byte(`{"status":`+test.status+`}`))
+		if test.want == nil && err == nil {
+			t.Errorf("status %s: parseLabState() = %s, want an error", test.status, formatState(state.Open))
+		}
+		if test.want == nil && err != nil && !strings.Contains(err.Error(), "unknown state") {
+			t.Errorf("status %s: parseLabState() = %v, want an unknown state error", test.status, err)
+		}
+		if test.want != nil && (err != nil || !equalState(state.Open, test.want)) {
+			t.Errorf("status %s: parseLabState() = %s, %v, want %s", test.status, formatState(state.Open), err, formatState(test.want))
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// mastodonStatus is the part of a Mastodon status (post) that is published as an event
+type mastodonStatus struct {
+	CreatedAt   time.Time `json:"created_at"`
+	URL         string    `json:"url"`
+	Content     string    `json:"content"`
+	SpoilerText string    `json:"spoiler_text"`
+}
+
+// mastodonAccount is the part of a Mastodon account needed to list its statuses
+type mastodonAccount struct {
+	ID string `json:"id"`
+}
+
+// parseMastodonAccount splits an account like @metalab@chaos.social into the user and the instance
+func parseMastodonAccount(account string) (user, instance string, err error) {
+	user, instance, ok := strings.Cut(strings.TrimPrefix(account, "@"), "@")
+	if !ok || user == "" || instance == "" || strings.ContainsAny(instance, "/@") {
+		return "", "", fmt.Errorf("invalid mastodon account %q: must be @user@instance", account)
+	}
+	return user, instance, nil
+}
+
+// fetchMastodonEvents fetches the recent posts of the configured account carrying the configured hashtag
+func (s *Server) fetchMastodonEvents() ([]Event, error) {
+	user, instance, err := parseMastodonAccount(s.config.MastodonAccount)
+	if err != nil {
+		return nil, err
+	}
+	base := "https://" + instance
+
+	var account mastodonAccount
+	if err := s.fetchMastodon(instance, base+"/api/v1/accounts/lookup?acct="+url.QueryEscape(user), &account); err != nil {
+		s.logger.Warn("mastodon account unavailable", "account", s.config.MastodonAccount, "error", err)
+		return nil, err
+	}
+	query := url.Values{
+		"tagged":          {s.config.MastodonTag},
+		"exclude_replies": {"true"},
+		"exclude_reblogs": {"true"},
+		"limit":           {fmt.Sprint(s.config.EventsMax)},
+	}
+	var statuses []mastodonStatus
+	if err := s.fetchMastodon(instance, base+"/api/v1/accounts/"+url.PathEscape(account.ID)+"/statuses?"+query.Encode(), &statuses); err != nil {
+		s.logger.Warn("mastodon posts unavailable", "account", s.config.MastodonAccount, "error", err)
+		return nil, err
+	}
+	return mastodonEvents(statuses), nil
+}
+
+// fetchMastodon requests a Mastodon API endpoint of instance and unmarshals the response into v,
+// once the instance reports the rate limit is used up no further requests are made until it resets
+func (s *Server) fetchMastodon(instance, url string, v any) error {
+	now := s.clock.Now()
+	if until, ok := s.mastodonThrottle.get(instance, now); ok {
+		return &throttledError{Until: until}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.StateFetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if until, ok := mastodonRateLimitReset(resp, now); ok {
+		s.logger.Warn("mastodon rate limit reached", "instance", instance, "until", until)
+		s.mastodonThrottle.set(instance, until)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s returned status %d (%s)", url, resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("error while unmarshalling response %q from %s: %w", truncate(string(body), 100), url, err)
+	}
+	return nil
+}
+
+// mastodonRateLimitReset returns until when requests have to be suspended, either because of a 429 response
+// or because the X-RateLimit-Remaining header reports no requests left
+func mastodonRateLimitReset(resp *http.Response, now time.Time) (until time.Time, ok bool) {
+	if resp.StatusCode == http.StatusTooManyRequests {
+		if until, ok := parseRetryAfter(resp.Header.Get("Retry-After"), now); ok {
+			return until, true
+		}
+	} else if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return time.Time{}, false
+	}
+	reset, err := time.Parse(time.RFC3339, resp.Header.Get("X-RateLimit-Reset"))
+	if err != nil || reset.After(now.Add(maxRetryAfter)) {
+		//mastodon resets its limits every five minutes
+		return now.Add(5 * time.Minute), true
+	}
+	return reset, true
+}
+
+// htmlTag matches the tags of the HTML content of a status
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// mastodonEvents maps statuses to events, named after the content warning or the first line of the post
+func mastodonEvents(statuses []mastodonStatus) []Event {
+	events := make([]Event, 0, len(statuses))
+	for _, status := range statuses {
+		name := strings.TrimSpace(status.SpoilerText)
+		if name == "" {
+			text := strings.NewReplacer("<br>", "\n", "<br/>", "\n", "<br />", "\n", "</p>", "\n").Replace(status.Content)
+			text = html.UnescapeString(htmlTag.ReplaceAllString(text, ""))
+			name, _, _ = strings.Cut(strings.TrimSpace(text), "\n")
+		}
+		if name == "" {
+			continue
+		}
+		if runes := []rune(name); len(runes) > 100 {
+			name = string(runes[:100]) + "..."
+		}
+		events = append(events, Event{
+			Name:      name,
+			Type:      "mastodon",
+			Timestamp: status.CreatedAt.Unix(),
+			Extra:     status.URL,
+		})
+	}
+	return events
+}
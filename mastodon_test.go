@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newMastodonInstance serves captured responses of the Mastodon API of chaos.social with header added, it returns
+// a server configured with the account on that instance and the number of requests made to it
+func newMastodonInstance(t *testing.T, header http.Header) (s *Server, requests *atomic.Int32) {
+	t.Helper()
+	requests = new(atomic.Int32)
+	instance := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		for key, values := range header {
+			w.Header()[key] = values
+		}
+		switch {
+		case r.URL.Path == "/api/v1/accounts/lookup" && r.URL.Query().Get("acct") == "metalab":
+			http.ServeFile(w, r, "testdata/mastodon_account.json")
+		case r.URL.Path == "/api/v1/accounts/109343958624389134/statuses":
+			query := r.URL.Query()
+			if query.Get("tagged") != "event" || query.Get("exclude_replies") != "true" || query.Get("exclude_reblogs") != "true" {
+				t.Errorf("statuses requested with query %s, want tagged event without replies and reblogs", r.URL.RawQuery)
+			}
+			http.ServeFile(w, r, "testdata/mastodon_statuses.json")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(instance.Close)
+
+	//the instance is always requested via https, so the test server is addressed by its host and port
+	config := testConfig(t)
+	config.MastodonAccount = "@metalab@" + strings.TrimPrefix(instance.URL, "https://")
+	s, _ = newTestServer(t, config, staticState(LabState{Open: Pointer(true)}), WithHTTPClient(instance.Client()))
+	return s, requests
+}
+
+func TestFetchMastodonEvents(t *testing.T) {
+	s, _ := newMastodonInstance(t, nil)
+	events, err := s.fetchMastodonEvents()
+	if err != nil {
+		t.Fatalf("fetchMastodonEvents() = %v", err)
+	}
+	want := []Event{
+		{
+			Name:      "Lötworkshop für Einsteiger:innen am Samstag ab 14 Uhr & open end!",
+			Type:      "mastodon",
+			Timestamp: time.Date(2024, 2, 28, 17, 30, 12, 0, time.UTC).Unix(),
+			Extra:     "https://chaos.social/@metalab/111990321881239021",
+		},
+		{
+			Name:      "Linux Install Party",
+			Type:      "mastodon",
+			Timestamp: time.Date(2024, 2, 25, 12, 0, 0, 0, time.UTC).Unix(),
+			Extra:     "https://chaos.social/@metalab/111972004118650112",
+		},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("events = %+v, want %+v", events, want)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("events[%d] = %+v, want %+v", i, events[i], want[i])
+		}
+	}
+
+	//the posts are added to the events of the document
+	doc := decodeJSON[SpaceAPIv15](t, serve(s, "GET", "/v15", nil))
+	if len(doc.Events) != 2 || doc.Events[1].Name != "Linux Install Party" {
+		t.Errorf("events of the document = %+v, want the mastodon posts", doc.Events)
+	}
+}
+
+func TestFetchMastodonEventsRateLimit(t *testing.T) {
+	s, requests := newMastodonInstance(t, http.Header{
+		"X-Ratelimit-Remaining": {"0"},
+		"X-Ratelimit-Reset":     {testNow.Add(3 * time.Minute).Format(time.RFC3339)},
+	})
+	if _, err := s.fetchMastodonEvents(); err == nil {
+		t.Fatal("fetchMastodonEvents() succeeded, want the second request to be throttled")
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("instance was requested %d times, want once until the rate limit resets", got)
+	}
+	if until, ok := mastodonRateLimitReset(&http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"30"}}}, testNow); !ok || !until.Equal(testNow.Add(30*time.Second)) {
+		t.Errorf("mastodonRateLimitReset(429, Retry-After 30) = %s, %v, want %s", until, ok, testNow.Add(30*time.Second))
+	}
+}
+
+func TestParseMastodonAccount(t *testing.T) {
+	if user, instance, err := parseMastodonAccount("@metalab@chaos.social"); err != nil || user != "metalab" || instance != "chaos.social" {
+		t.Errorf("parseMastodonAccount(@metalab@chaos.social) = %q, %q, %v", user, instance, err)
+	}
+	for _, account := range []string{"metalab", "@metalab", "@@chaos.social", "@metalab@chaos.social/evil"} {
+		if _, _, err := parseMastodonAccount(account); err == nil {
+			t.Errorf("parseMastodonAccount(%q) succeeded, want an error", account)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	openGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "spaceapi_open",
+		Help: "Whether the space is open (1), closed (0) or in an unknown state (NaN).",
+	})
+	stateFetchTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "spaceapi_state_fetch_total",
+		Help: "Number of lab state fetches from the state api by result.",
+	}, []string{"result"})
+	stateFetchDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "spaceapi_state_fetch_duration_seconds",
+		Help:    "Latency of lab state fetches from the state api.",
+		Buckets: prometheus.DefBuckets,
+	})
+	httpRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "spaceapi_http_requests_total",
+		Help: "Number of http requests by endpoint and status code.",
+	}, []string{"endpoint", "code"})
+	cacheHitsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "spaceapi_cache_hits_total",
+		Help: "Number of lookups answered from the cache by source.",
+	}, []string{"cache"})
+	cacheMissesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "spaceapi_cache_misses_total",
+		Help: "Number of lookups and refreshes that had to fetch from the upstream by source.",
+	}, []string{"cache"})
+)
+
+func init() {
+	//the state is unknown until the first successful fetch
+	openGauge.Set(math.NaN())
+}
+
+// observeStateFetch records the result and latency of a lab state fetch
+func observeStateFetch(start time.Time, err error) {
+	stateFetchDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		stateFetchTotal.WithLabelValues("error").Inc()
+	} else {
+		stateFetchTotal.WithLabelValues("success").Inc()
+	}
+}
+
+// setOpenGauge reflects the lab state in the open gauge, an unknown state is exported as NaN
+func setOpenGauge(open *bool) {
+	switch {
+	case open == nil:
+		openGauge.Set(math.NaN())
+	case *open:
+		openGauge.Set(1)
+	default:
+		openGauge.Set(0)
+	}
+}
+
+// countRequests counts the requests per registered endpoint and status code
+func countRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		//the pattern is set by the ServeMux, using it instead of the path keeps the label cardinality bounded
+		endpoint := r.Pattern
+		if endpoint == "" {
+			endpoint = "unmatched"
+		}
+		httpRequestsTotal.WithLabelValues(endpoint, strconv.Itoa(rec.status)).Inc()
+	})
+}
+
+var (
+	temperatureDesc    = prometheus.NewDesc("spaceapi_temperature_celsius", "Temperature reported by a sensor.", []string{"location", "name"}, nil)
+	co2Desc            = prometheus.NewDesc("spaceapi_co2_ppm", "CO2 concentration reported by a sensor.", []string{"location", "name"}, nil)
+	humidityDesc       = prometheus.NewDesc("spaceapi_humidity_percent", "Relative humidity reported by a sensor.", []string{"location", "name"}, nil)
+	barometerDesc      = prometheus.NewDesc("spaceapi_barometer_hpa", "Barometric pressure reported by a sensor.", []string{"location", "name"}, nil)
+	radiationDesc      = prometheus.NewDesc("spaceapi_radiation", "Radiation reported by a sensor, in the unit given by the unit label.", []string{"location", "name", "type", "unit"}, nil)
+	doorLockedDesc     = prometheus.NewDesc("spaceapi_door_locked", "Whether a door is locked (1) or not (0).", []string{"location", "name"}, nil)
+	beverageSupplyDesc = prometheus.NewDesc("spaceapi_beverage_supply", "Stock of a beverage, in the unit given by the unit label.", []string{"location", "name", "unit"}, nil)
+)
+
+// sensorCollector exports the current sensor block as labelled gauges, sensors without a current reading are left out
+type sensorCollector struct {
+	server *Server
+}
+
+// Describe sends nothing, which makes this an unchecked collector as the exported sensors vary over time
+func (sensorCollector) Describe(chan<- *prometheus.Desc) {}
+
+func (c sensorCollector) Collect(ch chan<- prometheus.Metric) {
+	sensors := c.server.currentSensors(context.Background(), c.server.doc.Sensors)
+	if sensors == nil {
+		return
+	}
+
+	//two sensors with the same labels would make the whole scrape fail, so only the first one is exported
+	seen := make(map[string]bool)
+	gauge := func(desc *prometheus.Desc, value float64, labels ...string) {
+		key := desc.String() + strings.Join(labels, "\x00")
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, labels...)
+	}
+
+	for _, sensor := range sensors.Temperature {
+		celsius, err := convertTemperature(sensor.Value, sensor.Unit, "°C")
+		if err != nil {
+			continue
+		}
+		gauge(temperatureDesc, celsius, sensor.Location, sensor.Name)
+	}
+	for _, sensor := range sensors.CarbonDioxide {
+		gauge(co2Desc, sensor.Value, sensor.Location, sensor.Name)
+	}
+	for _, sensor := range sensors.Humidity {
+		gauge(humidityDesc, sensor.Value, sensor.Location, sensor.Name)
+	}
+	for _, sensor := range sensors.Barometer {
+		hectopascal, err := convertPressureToHectopascal(sensor.Value, sensor.Unit)
+		if err != nil {
+			continue
+		}
+		gauge(barometerDesc, hectopascal, sensor.Location, sensor.Name)
+	}
+	if sensors.Radiation != nil {
+		for radiationType, readings := range map[string][]RadiationSensor{
+			"alpha":      sensors.Radiation.Alpha,
+			"beta":       sensors.Radiation.Beta,
+			"gamma":      sensors.Radiation.Gamma,
+			"beta_gamma": sensors.Radiation.BetaGamma,
+		} {
+			for _, sensor := range readings {
+				gauge(radiationDesc, sensor.Value, sensor.Location, sensor.Name, radiationType, sensor.Unit)
+			}
+		}
+	}
+	for _, sensor := range sensors.DoorLocked {
+		locked := 0.0
+		if sensor.Value {
+			locked = 1
+		}
+		gauge(doorLockedDesc, locked, sensor.Location, sensor.Name)
+	}
+	for _, sensor := range sensors.BeverageSupply {
+		gauge(beverageSupplyDesc, sensor.Value, sensor.Location, sensor.Name, sensor.Unit)
+	}
+}
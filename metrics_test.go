@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// cacheCounts returns the current hit and miss counters of a cache, the counters are shared by all tests
+func cacheCounts(cache string) (hits, misses float64) {
+	return testutil.ToFloat64(cacheHitsTotal.WithLabelValues(cache)), testutil.ToFloat64(cacheMissesTotal.WithLabelValues(cache))
+}
+
+func TestStateCacheMetrics(t *testing.T) {
+	config := testConfig(t)
+	config.StateCacheTTL = time.Minute
+	s, clock := newTestServer(t, config, staticState(LabState{Open: Pointer(true)}))
+	hits, misses := cacheCounts("state")
+
+	//only a fresh state is a hit, every fetch is a miss and a state served stale is neither
+	steps := []struct {
+		advance      time.Duration
+		hits, misses float64
+	}{
+		{0, 0, 1}, //nothing fetched yet
+		{0, 1, 0},
+		{30 * time.Second, 1, 0},
+		{time.Minute, 0, 0}, //expired, served stale until the poller refreshes it
+	}
+	for i, step := range steps {
+		clock.Advance(step.advance)
+		serve(s, "GET", "/state", nil)
+		hits, misses = hits+step.hits, misses+step.misses
+		if gotHits, gotMisses := cacheCounts("state"); gotHits != hits || gotMisses != misses {
+			t.Errorf("after request %d: hits = %v, misses = %v, want %v, %v", i, gotHits, gotMisses, hits, misses)
+		}
+	}
+
+	s.refreshLabState(context.Background())
+	serve(s, "GET", "/state", nil)
+	if gotHits, gotMisses := cacheCounts("state"); gotHits != hits+1 || gotMisses != misses+1 {
+		t.Errorf("after a refresh and a request: hits = %v, misses = %v, want %v, %v", gotHits, gotMisses, hits+1, misses+1)
+	}
+}
+
+func TestValueCacheMetrics(t *testing.T) {
+	clock := newFakeClock(testNow)
+	cache := &valueCache[int]{name: "metrics_test", ttl: time.Minute, clock: clock}
+	fetch := func() (int, error) { return 1, nil }
+	hits, misses := cacheCounts("metrics_test")
+
+	//miss, hit, hit, expired miss, hit
+	for _, advance := range []time.Duration{0, 0, 59 * time.Second, 2 * time.Second, 0} {
+		clock.Advance(advance)
+		cache.getOrFetch(context.Background(), fetch)
+	}
+	if gotHits, gotMisses := cacheCounts("metrics_test"); gotHits-hits != 3 || gotMisses-misses != 2 {
+		t.Errorf("hits = %v, misses = %v, want 3 and 2 more than %v, %v", gotHits, gotMisses, hits, misses)
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	s, _ := newTestServer(t, testConfig(t), staticState(LabState{Open: Pointer(true)}))
+	serve(s, "GET", "/v15", nil)
+	body := serve(s, "GET", "/metrics", nil).Body.String()
+	for _, metric := range []string{`spaceapi_cache_hits_total{cache="state"}`, `spaceapi_cache_misses_total{cache="state"}`} {
+		if !strings.Contains(body, metric) {
+			t.Errorf("/metrics doesn't include %s", metric)
+		}
+	}
+}
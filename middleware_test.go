@@ -0,0 +1,166 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecoverPanics(t *testing.T) {
+	s, _ := newTestServer(t, testConfig(t), staticState(LabState{}))
+	s.mux.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("deliberate panic")
+	})
+
+	w := serve(s, "GET", "/panic", nil)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", contentType)
+	}
+	if body := decodeJSON[map[string]string](t, w); body["error"] != "internal server error" {
+		t.Errorf("body = %v, want a generic error", body)
+	}
+	//the server keeps working after the panic
+	if w := serve(s, "GET", "/v15", nil); w.Code != http.StatusOK {
+		t.Errorf("status after the panic = %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+// slowEndpoint registers a handler at /slow that answers after delay, or once the request is cancelled
+func slowEndpoint(s *Server, delay time.Duration) {
+	s.mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+			w.Write([]byte("done"))
+		case <-r.Context().Done():
+		}
+	})
+}
+
+func TestRequestTimeout(t *testing.T) {
+	config := testConfig(t)
+	config.RequestTimeout = 50 * time.Millisecond
+	config.AllowedOrigins = []string{"https://metalab.at"}
+	s, _ := newTestServer(t, config, staticState(LabState{Open: Pointer(true)}))
+	slowEndpoint(s, 5*time.Second)
+
+	start := time.Now()
+	w := serve(s, "GET", "/slow", nil)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("request took %s, want it to end at the request timeout", elapsed)
+	}
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+	if body := w.Body.String(); body != "request timed out" {
+		t.Errorf("body = %q, want the timeout message", body)
+	}
+
+	//headers set by the middleware before the timeout handler are kept on regular responses
+	w = serve(s, "GET", "/v15", http.Header{"Origin": {"https://metalab.at"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if vary := strings.Join(w.Header().Values("Vary"), ", "); !strings.Contains(vary, "Origin") || !strings.Contains(vary, "Accept-Encoding") {
+		t.Errorf("Vary = %q, want Origin and Accept-Encoding", vary)
+	}
+	if origin := w.Header().Get("Access-Control-Allow-Origin"); origin != "https://metalab.at" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want the allowed origin", origin)
+	}
+}
+
+func TestRequestTimeoutDisabled(t *testing.T) {
+	config := testConfig(t)
+	config.RequestTimeout = 0
+	s, _ := newTestServer(t, config, staticState(LabState{}))
+	slowEndpoint(s, 100*time.Millisecond)
+
+	w := serve(s, "GET", "/slow", nil)
+	if w.Code != http.StatusOK || w.Body.String() != "done" {
+		t.Errorf("status = %d, body = %q, want the slow handler to finish without a request timeout", w.Code, w.Body.String())
+	}
+}
+
+func TestSlowSourceWithinRequestTimeout(t *testing.T) {
+	config := testConfig(t)
+	config.RequestTimeout = time.Second
+	config.StateFetchDeadline = 100 * time.Millisecond
+	//the shared fetch goes on after the request stopped waiting for it
+	config.StateFetchTimeout = 200 * time.Millisecond
+	sensors := httptest.NewServer(slowHandler(5 * time.Second))
+	defer sensors.Close()
+	config.TemperatureSensorsURL = sensors.URL
+	s, _ := newTestServer(t, config, staticState(LabState{Open: Pointer(true)}))
+
+	//the document is served without the sensors once the upstreams exceed their deadline, before the request times out
+	start := time.Now()
+	w := serve(s, "GET", "/v15", nil)
+	if elapsed := time.Since(start); elapsed > config.RequestTimeout/2 {
+		t.Errorf("request took %s, want it to stop waiting for the sensors after %s", elapsed, config.StateFetchDeadline)
+	}
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if doc := decodeJSON[SpaceAPIv15](t, w); doc.Sensors != nil {
+		t.Errorf("sensors = %+v, want none", doc.Sensors)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	handler := readOnly(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"space":"Metalab"}`))
+	})
+	for _, method := range []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"} {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(method, "/v15", nil))
+		allowed := method == "GET" || method == "HEAD"
+		if allowed && w.Code != http.StatusOK {
+			t.Errorf("%s: status = %d, want %d", method, w.Code, http.StatusOK)
+		}
+		if !allowed && (w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET, HEAD") {
+			t.Errorf("%s: status = %d, Allow = %q, want %d with GET, HEAD", method, w.Code, w.Header().Get("Allow"), http.StatusMethodNotAllowed)
+		}
+		if !allowed && strings.Contains(w.Body.String(), "Metalab") {
+			t.Errorf("%s: body = %q, want no document", method, w.Body.String())
+		}
+	}
+}
+
+func TestAdminMethodNotAllowed(t *testing.T) {
+	config := testConfig(t)
+	config.AdminToken = "s3cret"
+	s, _ := newTestServer(t, config, staticState(LabState{Open: Pointer(true)}))
+	tests := []struct {
+		method, target string
+		allow          string
+	}{
+		{"GET", "/admin/state", "POST, DELETE"},
+		{"PUT", "/admin/state", "POST, DELETE"},
+		{"GET", "/admin/refresh", "POST"},
+		{"DELETE", "/admin/refresh", "POST"},
+		{"POST", "/debug/config", "GET, HEAD"},
+	}
+	for _, test := range tests {
+		w := serve(s, test.method, test.target, http.Header{"Authorization": {"Bearer s3cret"}})
+		if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != test.allow {
+			t.Errorf("%s %s: status = %d, Allow = %q, want %d with %s", test.method, test.target, w.Code, w.Header().Get("Allow"), http.StatusMethodNotAllowed, test.allow)
+		}
+	}
+
+	//the token is checked before the method, so the admin endpoints aren't revealed
+	if w := serve(s, "GET", "/admin/refresh", nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("GET /admin/refresh without a token: status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestPreflightAllowed(t *testing.T) {
+	s, _ := newTestServer(t, testConfig(t), staticState(LabState{Open: Pointer(true)}))
+	w := serve(s, "OPTIONS", "/v15", http.Header{"Origin": {"https://metalab.at"}, "Access-Control-Request-Method": {"GET"}})
+	if w.Code != http.StatusNoContent {
+		t.Errorf("status = %d, want %d for a CORS preflight", w.Code, http.StatusNoContent)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// newMQTTClient configures a client for the configured broker which reconnects on its own,
+// every (re)connect publishes the current state so the retained message is never outdated
+func (s *Server) newMQTTClient() mqtt.Client {
+	opts := mqtt.NewClientOptions().
+		AddBroker(s.config.MQTTBroker).
+		SetClientID(s.config.MQTTClientID).
+		SetUsername(s.config.MQTTUsername).
+		SetPassword(s.config.MQTTPassword).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectTimeout(s.config.StateFetchTimeout).
+		SetOnConnectHandler(s.onMQTTConnect).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			s.logger.Warn("mqtt connection lost", "broker", s.config.MQTTBroker, "error", err)
+		})
+	return mqtt.NewClient(opts)
+}
+
+// onMQTTConnect is called by the client on every successful (re)connect
+func (s *Server) onMQTTConnect(client mqtt.Client) {
+	s.logger.Info("mqtt connected", "broker", s.config.MQTTBroker)
+	if event, ok := s.currentStateEvent(); ok {
+		s.publishMQTTState(client, event)
+	}
+	//subscriptions don't survive a reconnect with a clean session
+	s.subscribeMQTTSensors(client)
+}
+
+// runMQTTPublisher connects to the broker and publishes every state change until ctx is cancelled
+func (s *Server) runMQTTPublisher(ctx context.Context, client mqtt.Client) {
+	events := s.stateChanges.subscribe()
+	defer s.stateChanges.unsubscribe(events)
+
+	//with connect retry enabled this only fails for an invalid configuration, the client keeps retrying otherwise
+	if token := client.Connect(); token.WaitTimeout(s.config.StateFetchTimeout) && token.Error() != nil {
+		s.logger.Error("error while connecting to mqtt broker", "broker", s.config.MQTTBroker, "error", token.Error())
+		return
+	}
+	defer client.Disconnect(uint((250 * time.Millisecond).Milliseconds()))
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			//the broadcaster is closed on shutdown, the retained state must not be replaced by a zero event
+			if !ok {
+				return
+			}
+			s.publishMQTTState(client, event)
+		}
+	}
+}
+
+// publishMQTTState publishes the state as a retained message, so new subscribers get it right away
+func (s *Server) publishMQTTState(client mqtt.Client, event StateEvent) {
+	if !client.IsConnected() {
+		s.logger.Warn("mqtt not connected, state will be published on reconnect", "broker", s.config.MQTTBroker)
+		return
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		s.logger.Error("error while marshalling mqtt state", "error", err)
+		return
+	}
+
+	token := client.Publish(s.config.MQTTStateTopic, 1, true, payload)
+	go func() {
+		if token.WaitTimeout(s.config.StateFetchTimeout) && token.Error() != nil {
+			s.logger.Warn("error while publishing state to mqtt", "topic", s.config.MQTTStateTopic, "error", token.Error())
+		}
+	}()
+}
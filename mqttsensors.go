@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// MQTTSensorTopic maps an MQTT topic to a sensor of the SpaceAPI document
+type MQTTSensorTopic struct {
+	Topic    string
+	Type     string // temperature, co2 or humidity
+	Location string
+}
+
+// mqttSensorValue is the latest reading received on a sensor topic
+type mqttSensorValue struct {
+	Value     float64
+	Unit      string
+	UpdatedAt time.Time
+}
+
+// mqttSensorStore keeps the latest reading of every sensor topic
+type mqttSensorStore struct {
+	mu     sync.RWMutex
+	values map[string]mqttSensorValue
+	clock  Clock
+}
+
+// set stores the latest reading of topic
+func (s *mqttSensorStore) set(topic string, value mqttSensorValue) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.values[topic] = value
+}
+
+// get returns the latest reading of topic, ok is false if there is none or it is older than maxAge
+func (s *mqttSensorStore) get(topic string, maxAge time.Duration) (value mqttSensorValue, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	value, ok = s.values[topic]
+	if !ok || s.clock.Now().Sub(value.UpdatedAt) > maxAge {
+		return mqttSensorValue{}, false
+	}
+	return value, true
+}
+
+// parseMQTTSensorTopics parses a list of topic=type:location mappings
+func parseMQTTSensorTopics(mappings []string) ([]MQTTSensorTopic, error) {
+	var topics []MQTTSensorTopic
+	for _, mapping := range mappings {
+		topic, sensor, ok := strings.Cut(mapping, "=")
+		sensorType, location, hasLocation := strings.Cut(sensor, ":")
+		if !ok || topic == "" || !hasLocation || location == "" {
+			return nil, fmt.Errorf("invalid mqtt sensor topic %q: must be topic=type:location", mapping)
+		}
+		switch sensorType {
+		case "temperature", "co2", "humidity":
+		default:
+			return nil, fmt.Errorf("invalid mqtt sensor topic %q: unknown sensor type %q", mapping, sensorType)
+		}
+		topics = append(topics, MQTTSensorTopic{Topic: topic, Type: sensorType, Location: location})
+	}
+	return topics, nil
+}
+
+// subscribeMQTTSensors subscribes to all configured sensor topics, it is called on every (re)connect
+func (s *Server) subscribeMQTTSensors(client mqtt.Client) {
+	for _, topic := range s.config.MQTTSensorTopics {
+		token := client.Subscribe(topic.Topic, 0, s.handleMQTTSensorMessage)
+		go func() {
+			if token.WaitTimeout(s.config.StateFetchTimeout) && token.Error() != nil {
+				s.logger.Warn("error while subscribing to mqtt sensor topic", "topic", topic.Topic, "error", token.Error())
+			}
+		}()
+	}
+}
+
+// handleMQTTSensorMessage stores a reading received on a sensor topic
+func (s *Server) handleMQTTSensorMessage(_ mqtt.Client, msg mqtt.Message) {
+	value, err := parseMQTTSensorPayload(msg.Payload(), s.clock.Now())
+	if err != nil {
+		s.logger.Warn("dropping invalid mqtt sensor reading", "topic", msg.Topic(), "error", err)
+		return
+	}
+	s.mqttSensors.set(msg.Topic(), value)
+}
+
+// parseMQTTSensorPayload parses a reading which is either a plain number or a JSON object with a value, an optional unit
+// and an optional timestamp, receivedAt is used as the time of the reading if it doesn't report one
+func parseMQTTSensorPayload(payload []byte, receivedAt time.Time) (mqttSensorValue, error) {
+	if value, err := strconv.ParseFloat(strings.TrimSpace(string(payload)), 64); err == nil {
+		return mqttSensorValue{Value: value, UpdatedAt: receivedAt}, nil
+	}
+
+	var reading SensorReading
+	if err := json.Unmarshal(payload, &reading); err != nil {
+		return mqttSensorValue{}, fmt.Errorf("error while parsing payload %q: %w", truncate(string(payload), 100), err)
+	}
+	return mqttSensorValue{Value: reading.Value, Unit: reading.Unit, UpdatedAt: time.Unix(lastChange(reading.Timestamp, receivedAt), 0)}, nil
+}
+
+// mergeMQTTSensors adds the fresh readings of all configured sensor topics to sensors
+func (s *Server) mergeMQTTSensors(sensors *Sensors) {
+	//the slices may be shared with the static document or a cache, appending must not write into their arrays
+	sensors.Temperature = slices.Clip(sensors.Temperature)
+	sensors.CarbonDioxide = slices.Clip(sensors.CarbonDioxide)
+	sensors.Humidity = slices.Clip(sensors.Humidity)
+
+	for _, topic := range s.config.MQTTSensorTopics {
+		value, ok := s.mqttSensors.get(topic.Topic, s.config.MQTTSensorMaxAge)
+		if !ok {
+			continue
+		}
+		base := BaseSensor{Location: topic.Location, LastChange: value.UpdatedAt.Unix()}
+
+		switch topic.Type {
+		case "temperature":
+			unit := value.Unit
+			if unit == "" {
+				unit = "°C"
+			}
+			converted, err := convertTemperature(value.Value, unit, s.config.TemperatureUnit)
+			if err != nil {
+				s.logger.Warn("dropping temperature reading", "topic", topic.Topic, "error", err)
+				continue
+			}
+			sensors.Temperature = append(sensors.Temperature, TempSensor{BaseSensor: base, Value: converted, Unit: s.config.TemperatureUnit})
+		case "co2":
+			sensors.CarbonDioxide = append(sensors.CarbonDioxide, CO2Sensor{BaseSensor: base, Value: value.Value, Unit: "ppm"})
+		case "humidity":
+			sensors.Humidity = append(sensors.Humidity, HumiditySensor{BaseSensor: base, Value: value.Value, Unit: "%"})
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseMQTTSensorPayload(t *testing.T) {
+	const measured = 1709315000 //a few minutes before testNow
+	tests := []struct {
+		payload string
+		want    mqttSensorValue
+	}{
+		{" 21.5\n", mqttSensorValue{Value: 21.5, UpdatedAt: testNow}},
+		{`{"value": 70.5, "unit": "°F"}`, mqttSensorValue{Value: 70.5, Unit: "°F", UpdatedAt: testNow}},
+		//the time the reading was taken is kept, not the time it was received
+		{`{"value": 420, "timestamp": 1709315000}`, mqttSensorValue{Value: 420, UpdatedAt: time.Unix(measured, 0)}},
+	}
+	for _, test := range tests {
+		got, err := parseMQTTSensorPayload([]byte(test.payload), testNow)
+		if err != nil {
+			t.Errorf("parseMQTTSensorPayload(%q): %v", test.payload, err)
+			continue
+		}
+		if got.Value != test.want.Value || got.Unit != test.want.Unit || !got.UpdatedAt.Equal(test.want.UpdatedAt) {
+			t.Errorf("parseMQTTSensorPayload(%q) = %+v, want %+v", test.payload, got, test.want)
+		}
+	}
+
+	if _, err := parseMQTTSensorPayload([]byte("warm"), testNow); err == nil {
+		t.Error("parseMQTTSensorPayload(\"warm\") succeeded, want an error")
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// prefersXML reports whether the Accept header ranks XML above JSON, JSON wins ties and wildcards
+func prefersXML(accept string) bool {
+	var jsonQ, xmlQ float64
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		switch mediaType {
+		case "application/json", "application/*", "*/*":
+			jsonQ = max(jsonQ, q)
+		case "application/xml", "text/xml":
+			xmlQ = max(xmlQ, q)
+		}
+	}
+	return xmlQ > jsonQ
+}
+
+// writeNegotiated writes v as XML if the client prefers it, and as JSON otherwise
+func (s *Server) writeNegotiated(w http.ResponseWriter, r *http.Request, root string, v any) {
+	w.Header().Add("Vary", "Accept")
+	if !prefersXML(r.Header.Get("Accept")) {
+		s.writeJSON(w, r, v)
+		return
+	}
+	s.writeXML(w, r, root, v)
+}
+
+// writeXML writes v as an indented XML document with the given root element
+func (s *Server) writeXML(w http.ResponseWriter, r *http.Request, root string, v any) {
+	var body bytes.Buffer
+	body.WriteString(xml.Header)
+	encoder := xml.NewEncoder(&body)
+	encoder.Indent("", "    ")
+	if err := encoder.EncodeElement(v, xml.StartElement{Name: xml.Name{Local: root}}); err != nil {
+		s.logger.Error("error while marshalling response", "path", r.URL.Path, "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	if checkNotModified(w, r, body.Bytes()) {
+		return
+	}
+	w.Write(body.Bytes())
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestPrefersXML(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{"", false},
+		{"application/json", false},
+		{"application/xml", true},
+		{"text/xml", true},
+		{"*/*", false},
+		{"application/xml, application/json", false},
+		{"application/json;q=0.5, application/xml", true},
+		{"text/html, application/xml;q=0.9, */*;q=0.8", true},
+	}
+	for _, test := range tests {
+		if got := prefersXML(test.accept); got != test.want {
+			t.Errorf("prefersXML(%q) = %v, want %v", test.accept, got, test.want)
+		}
+	}
+}
+
+func TestSpaceAPIContentNegotiation(t *testing.T) {
+	s, _ := newTestServer(t, testConfig(t), staticState(LabState{Open: Pointer(true)}))
+	tests := []struct {
+		accept      string
+		contentType string
+	}{
+		{"", "application/json"},
+		{"application/json", "application/json"},
+		{"application/xml", "application/xml"},
+		{"text/xml", "application/xml"},
+	}
+	for _, test := range tests {
+		w := serve(s, "GET", "/v15", http.Header{"Accept": {test.accept}})
+		if contentType := w.Header().Get("Content-Type"); contentType != test.contentType {
+			t.Errorf("Accept %q: Content-Type = %q, want %q", test.accept, contentType, test.contentType)
+		}
+		if !strings.Contains(w.Header().Get("Vary"), "Accept") {
+			t.Errorf("Accept %q: Vary = %q, want it to include Accept", test.accept, w.Header().Get("Vary"))
+		}
+
+		var doc SpaceAPIv15
+		var err error
+		if test.contentType == "application/xml" {
+			err = xml.Unmarshal(w.Body.Bytes(), &doc)
+		} else {
+			err = json.Unmarshal(w.Body.Bytes(), &doc)
+		}
+		if err != nil {
+			t.Errorf("Accept %q: invalid body: %v", test.accept, err)
+			continue
+		}
+		if doc.Space != "Metalab" || doc.State == nil || doc.State.Open == nil || !*doc.State.Open {
+			t.Errorf("Accept %q: space = %q, state = %+v, want Metalab open", test.accept, doc.Space, doc.State)
+		}
+	}
+}
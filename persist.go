@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// PersistedState is the last successfully fetched lab state as stored on disk
+type PersistedState struct {
+	Open       *bool  `json:"open"`
+	LastChange *int64 `json:"lastchange,omitempty"`
+	Message    string `json:"message,omitempty"`
+	FetchedAt  int64  `json:"fetched_at"`
+}
+
+// saveState writes the lab state to path, replacing the file atomically
+func saveState(path string, state LabState, fetchedAt time.Time) error {
+	p, err := json.Marshal(PersistedState{
+		Open:       state.Open,
+		LastChange: state.LastChange,
+		Message:    state.Message,
+		FetchedAt:  fetchedAt.Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(p); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// loadState reads the lab state persisted at path
+func loadState(path string) (PersistedState, error) {
+	var state PersistedState
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return state, err
+	}
+	if err := json.Unmarshal(content, &state); err != nil {
+		return state, err
+	}
+	if state.FetchedAt == 0 {
+		return state, errors.New("missing fetched_at")
+	}
+	return state, nil
+}
+
+// restorePersistedState seeds the state cache from the state file, a missing or corrupt file is ignored
+func (s *Server) restorePersistedState(path string) {
+	state, err := loadState(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		s.logger.Warn("ignoring invalid state file", "path", path, "error", err)
+		return
+	}
+
+	s.stateCache.restore(LabState{Open: state.Open, LastChange: state.LastChange, Message: state.Message}, time.Unix(state.FetchedAt, 0))
+	setOpenGauge(state.Open)
+	s.logger.Info("restored lab state", "path", path, "open", formatState(state.Open), "fetched_at", state.FetchedAt)
+}
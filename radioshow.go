@@ -0,0 +1,279 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// layouts accepted for the start and end time of the radio show, the ones without an offset are interpreted in the space's time zone
+var radioShowDateTimeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04"}
+var radioShowTimeOfDayLayouts = []string{"15:04:05", "15:04"}
+
+// radioShowEpoch is the day a show given as times of day is scheduled from, so its calendar entry doesn't change
+// from one day to the next, rules with an interval count from here
+var radioShowEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// radioShowSchedule returns the first airing of the radio show and its recurrence, the start and end time are either
+// full ISO 8601 timestamps or times of day, which repeat daily unless rrule says otherwise and may run past midnight,
+// the first airing is the first one matching the rule, the rule is nil for a single show
+func radioShowSchedule(show *RadioShow, loc *time.Location, rrule string) (start, end time.Time, rule *recurrenceRule, err error) {
+	if show.StartTime == "" || show.EndTime == "" {
+		return start, end, nil, fmt.Errorf("radio show %q has no start or end time", show.Name)
+	}
+
+	startDateTime, startErr := parseRadioShowDateTime(show.StartTime, loc)
+	endDateTime, endErr := parseRadioShowDateTime(show.EndTime, loc)
+	if startErr == nil && endErr == nil {
+		start, end = startDateTime, endDateTime
+	} else {
+		startOfDay, startErr := parseRadioShowTimeOfDay(show.StartTime)
+		endOfDay, endErr := parseRadioShowTimeOfDay(show.EndTime)
+		if startErr != nil || endErr != nil {
+			return start, end, nil, fmt.Errorf("invalid time window of radio show %q: %q - %q", show.Name, show.StartTime, show.EndTime)
+		}
+		midnight := time.Date(radioShowEpoch.Year(), radioShowEpoch.Month(), radioShowEpoch.Day(), 0, 0, 0, 0, loc)
+		start, end = midnight.Add(startOfDay), midnight.Add(endOfDay)
+		//the show runs past midnight
+		if !end.After(start) {
+			end = end.AddDate(0, 0, 1)
+		}
+		if rrule == "" {
+			rrule = "FREQ=DAILY"
+		}
+	}
+	if rrule == "" {
+		return start, end, nil, nil
+	}
+
+	rule, err = parseRRule(rrule, loc)
+	if err != nil {
+		return start, end, nil, fmt.Errorf("invalid recurrence of radio show %q: %w", show.Name, err)
+	}
+	for first := range rule.occurrences(start) {
+		return first, first.Add(end.Sub(start)), rule, nil
+	}
+	return start, end, nil, fmt.Errorf("radio show %q never airs with recurrence %q", show.Name, rrule)
+}
+
+// radioShowOnAir reports whether the radio show is live at now, see radioShowSchedule for its schedule
+func radioShowOnAir(show *RadioShow, now time.Time, loc *time.Location, rrule string) (bool, error) {
+	start, end, rule, err := radioShowSchedule(show, loc, rrule)
+	if err != nil {
+		return false, err
+	}
+	if rule == nil {
+		return !now.Before(start) && now.Before(end), nil
+	}
+	for airing := range rule.occurrences(start) {
+		if airing.After(now) {
+			break
+		}
+		if now.Before(airing.Add(end.Sub(start))) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// parseRadioShowDateTime parses a full timestamp, times without an offset are interpreted in loc
+func parseRadioShowDateTime(value string, loc *time.Location) (time.Time, error) {
+	var err error
+	for _, layout := range radioShowDateTimeLayouts {
+		var t time.Time
+		if t, err = time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// parseRadioShowTimeOfDay parses a time of day as the duration since midnight
+func parseRadioShowTimeOfDay(value string) (time.Duration, error) {
+	var err error
+	for _, layout := range radioShowTimeOfDayLayouts {
+		var t time.Time
+		if t, err = time.Parse(layout, value); err == nil {
+			return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second, nil
+		}
+	}
+	return 0, err
+}
+
+// radioShowICal renders the radio show as an iCalendar feed in the space's time zone, see radioShowSchedule for its
+// schedule
+func (s *Server) radioShowICal(show *RadioShow, loc *time.Location, rrule string) (string, error) {
+	start, end, rule, err := radioShowSchedule(show, loc, rrule)
+	if err != nil {
+		return "", err
+	}
+	if rule != nil && rrule == "" {
+		rrule = "FREQ=DAILY"
+	}
+
+	const layout = "20060102T150405"
+	description := show.Description
+	if show.StreamURL != "" {
+		description = strings.TrimSpace(description + "\n" + show.StreamURL)
+	}
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//" + escapeICalText(s.doc.Space) + "//SpaceAPI radio show//EN",
+		"CALSCALE:GREGORIAN",
+	}
+	//the time zone definition must cover the first airing
+	lines = append(lines, icalTimezone(loc, start.Year()-1)...)
+	lines = append(lines,
+		"BEGIN:VEVENT",
+		//uid and stamp must not change between requests, otherwise calendars see a new event every time
+		"UID:radioshow@"+escapeICalText(s.doc.Space),
+		"DTSTAMP:"+start.UTC().Format(layout)+"Z",
+		"DTSTART;TZID="+loc.String()+":"+start.In(loc).Format(layout),
+		"DTEND;TZID="+loc.String()+":"+end.In(loc).Format(layout),
+		"SUMMARY:"+escapeICalText(show.Name),
+		"URL:"+show.URL,
+	)
+	if rrule != "" {
+		lines = append(lines, "RRULE:"+rrule)
+	}
+	if description != "" {
+		lines = append(lines, "DESCRIPTION:"+escapeICalText(description))
+	}
+	if len(show.Tags) > 0 {
+		tags := make([]string, 0, len(show.Tags))
+		for _, tag := range show.Tags {
+			tags = append(tags, escapeICalText(tag))
+		}
+		lines = append(lines, "CATEGORIES:"+strings.Join(tags, ","))
+	}
+	lines = append(lines, "END:VEVENT", "END:VCALENDAR")
+
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(foldICalLine(line))
+		b.WriteString("\r\n")
+	}
+	return b.String(), nil
+}
+
+// icalTimezone renders the VTIMEZONE of loc with the daylight saving rules in effect in year, each change is
+// repeated yearly on the same weekday of its month
+func icalTimezone(loc *time.Location, year int) []string {
+	const layout = "20060102T150405"
+	lines := []string{"BEGIN:VTIMEZONE", "TZID:" + loc.String()}
+
+	t := time.Date(year, 1, 1, 0, 0, 0, 0, loc)
+	var changes []time.Time
+	for {
+		_, next := t.ZoneBounds()
+		if next.IsZero() || next.Year() > year {
+			break
+		}
+		changes = append(changes, next)
+		t = next
+	}
+	if len(changes) == 0 {
+		name, offset := t.Zone()
+		lines = append(lines,
+			"BEGIN:STANDARD",
+			"DTSTART:19700101T000000",
+			"TZOFFSETFROM:"+formatICalOffset(offset),
+			"TZOFFSETTO:"+formatICalOffset(offset),
+			"TZNAME:"+name,
+			"END:STANDARD",
+		)
+	}
+	for _, change := range changes {
+		name, offset := change.Zone()
+		_, previous := change.Add(-time.Second).Zone()
+		component := "STANDARD"
+		if change.IsDST() {
+			component = "DAYLIGHT"
+		}
+		//observances start at the local time before the change
+		local := change.In(time.FixedZone(name, previous))
+		week := strconv.Itoa((local.Day()-1)/7 + 1)
+		if local.Day()+7 > time.Date(local.Year(), local.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day() {
+			week = "-1"
+		}
+		lines = append(lines,
+			"BEGIN:"+component,
+			"DTSTART:"+local.Format(layout),
+			"TZOFFSETFROM:"+formatICalOffset(previous),
+			"TZOFFSETTO:"+formatICalOffset(offset),
+			"TZNAME:"+name,
+			fmt.Sprintf("RRULE:FREQ=YEARLY;BYMONTH=%d;BYDAY=%s%s", local.Month(), week, icalWeekday(local.Weekday())),
+			"END:"+component,
+		)
+	}
+	return append(lines, "END:VTIMEZONE")
+}
+
+// formatICalOffset formats an offset from UTC in seconds as a UTC-OFFSET value like +0100
+func formatICalOffset(offset int) string {
+	sign := "+"
+	if offset < 0 {
+		sign, offset = "-", -offset
+	}
+	value := fmt.Sprintf("%s%02d%02d", sign, offset/3600, offset%3600/60)
+	if offset%60 != 0 {
+		value += fmt.Sprintf("%02d", offset%60)
+	}
+	return value
+}
+
+// icalWeekday returns the BYDAY code of a weekday
+func icalWeekday(weekday time.Weekday) string {
+	for code, d := range icalWeekdays {
+		if d == weekday {
+			return code
+		}
+	}
+	return ""
+}
+
+// escapeICalText escapes a value for use as an iCalendar TEXT value
+func escapeICalText(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(value)
+}
+
+// foldICalLine splits a content line into lines of at most 75 octets, continuation lines start with a space
+func foldICalLine(line string) string {
+	var b strings.Builder
+	width := 0
+	for _, r := range line {
+		size := utf8.RuneLen(r)
+		if width+size > 75 {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	return b.String()
+}
+
+// handleRadioShowICal serves the radio show schedule as an iCalendar feed
+func (s *Server) handleRadioShowICal(w http.ResponseWriter, r *http.Request) {
+	if s.doc.RadioShow == nil {
+		http.NotFound(w, r)
+		return
+	}
+	body, err := s.radioShowICal(s.doc.RadioShow, s.spaceLocation(), s.config.RadioShowRRule)
+	if err != nil {
+		s.logger.Error("error while rendering radio show calendar", "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	s.setCacheControl(w)
+	if checkNotModified(w, r, []byte(body)) {
+		return
+	}
+	w.Write([]byte(body))
+}
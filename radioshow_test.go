@@ -0,0 +1,104 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRadioShowOnAir(t *testing.T) {
+	vienna, err := time.LoadLocation("Europe/Vienna")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		start, end string
+		rrule      string
+		now        time.Time
+		want       bool
+	}{
+		{"before a single show", "2024-03-01T20:00", "2024-03-01T22:00", "", time.Date(2024, 3, 1, 19, 59, 0, 0, vienna), false},
+		{"during a single show", "2024-03-01T20:00", "2024-03-01T22:00", "", time.Date(2024, 3, 1, 20, 0, 0, 0, vienna), true},
+		{"after a single show", "2024-03-01T20:00", "2024-03-01T22:00", "", time.Date(2024, 3, 1, 22, 0, 0, 0, vienna), false},
+		{"single show with offset", "2024-03-01T19:00:00Z", "2024-03-01T21:00:00Z", "", time.Date(2024, 3, 1, 20, 30, 0, 0, time.UTC), true},
+		{"before a daily show", "20:00", "22:00", "", time.Date(2024, 3, 5, 19, 30, 0, 0, vienna), false},
+		{"during a daily show", "20:00", "22:00", "", time.Date(2024, 3, 5, 21, 30, 0, 0, vienna), true},
+		{"after a daily show", "20:00", "22:00", "", time.Date(2024, 3, 5, 22, 30, 0, 0, vienna), false},
+		//the time of day is taken in the space's time zone, 20:30 UTC is 21:30 in Vienna
+		{"daily show in the space's time zone", "21:00", "22:00", "", time.Date(2024, 3, 5, 20, 30, 0, 0, time.UTC), true},
+		{"before midnight of a show past midnight", "23:00", "01:00", "", time.Date(2024, 3, 5, 23, 30, 0, 0, vienna), true},
+		{"after midnight of a show past midnight", "23:00", "01:00", "", time.Date(2024, 3, 6, 0, 30, 0, 0, vienna), true},
+		{"after a show past midnight", "23:00", "01:00", "", time.Date(2024, 3, 6, 1, 30, 0, 0, vienna), false},
+		{"during a weekly show", "20:00", "22:00", "FREQ=WEEKLY;BYDAY=FR", time.Date(2024, 3, 1, 21, 0, 0, 0, vienna), true},
+		{"a weekly show on another day", "20:00", "22:00", "FREQ=WEEKLY;BYDAY=FR", time.Date(2024, 3, 5, 21, 0, 0, 0, vienna), false},
+		{"during a repeated single show", "2024-03-01T20:00", "2024-03-01T22:00", "FREQ=WEEKLY", time.Date(2024, 3, 8, 21, 0, 0, 0, vienna), true},
+		{"between repeats of a single show", "2024-03-01T20:00", "2024-03-01T22:00", "FREQ=WEEKLY", time.Date(2024, 3, 9, 21, 0, 0, 0, vienna), false},
+		{"after the last repeat of a single show", "2024-03-01T20:00", "2024-03-01T22:00", "FREQ=WEEKLY;COUNT=2", time.Date(2024, 3, 15, 21, 0, 0, 0, vienna), false},
+	}
+	for _, test := range tests {
+		show := &RadioShow{Name: "Metalab Radio", StartTime: test.start, EndTime: test.end}
+		onAir, err := radioShowOnAir(show, test.now, vienna, test.rrule)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if onAir != test.want {
+			t.Errorf("%s: on air = %v, want %v", test.name, onAir, test.want)
+		}
+	}
+}
+
+func TestRadioShowOnlyWhileOnAir(t *testing.T) {
+	doc := defaultSpaceDocument()
+	doc.RadioShow = &RadioShow{Name: "Metalab Radio", URL: "https://radio.metalab.at", Type: "mp3", StartTime: "20:00", EndTime: "22:00"}
+	s, clock := newTestServer(t, testConfig(t), staticState(LabState{}), WithDocument(doc))
+
+	vienna := s.spaceLocation()
+	clock.Set(time.Date(2024, 3, 1, 19, 0, 0, 0, vienna))
+	if doc := decodeJSON[SpaceAPIv15](t, serve(s, "GET", "/v15", nil)); doc.RadioShow != nil {
+		t.Error("radio show is advertised before it is on air")
+	}
+	clock.Set(time.Date(2024, 3, 1, 21, 0, 0, 0, vienna))
+	if doc := decodeJSON[SpaceAPIv15](t, serve(s, "GET", "/v15", nil)); doc.RadioShow == nil || doc.RadioShow.Name != "Metalab Radio" {
+		t.Errorf("radio show = %+v, want it advertised while on air", doc.RadioShow)
+	}
+}
+
+func TestRadioShowICal(t *testing.T) {
+	doc := defaultSpaceDocument()
+	doc.RadioShow = &RadioShow{Name: "Metalab Radio", URL: "https://radio.metalab.at", Type: "mp3", StartTime: "20:00", EndTime: "22:00"}
+	config := testConfig(t)
+	config.RadioShowRRule = "FREQ=WEEKLY;BYDAY=FR"
+	s, clock := newTestServer(t, config, staticState(LabState{}), WithDocument(doc))
+
+	body := serve(s, "GET", "/radioshow.ics", nil).Body.String()
+	//the calendar doesn't change from day to day, so calendars don't see a new event every time
+	clock.Advance(24 * time.Hour)
+	if next := serve(s, "GET", "/radioshow.ics", nil).Body.String(); next != body {
+		t.Errorf("calendar changed after a day:\n%s\nwant\n%s", next, body)
+	}
+	for _, line := range []string{
+		//the first friday from the start of 2024
+		"DTSTART;TZID=Europe/Vienna:20240105T200000",
+		"DTEND;TZID=Europe/Vienna:20240105T220000",
+		"DTSTAMP:20240105T190000Z",
+		"RRULE:FREQ=WEEKLY;BYDAY=FR",
+		"BEGIN:VTIMEZONE",
+		"TZID:Europe/Vienna",
+		"BEGIN:DAYLIGHT\r\nDTSTART:20230326T020000\r\nTZOFFSETFROM:+0100\r\nTZOFFSETTO:+0200\r\nTZNAME:CEST\r\nRRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU",
+		"BEGIN:STANDARD\r\nDTSTART:20231029T030000\r\nTZOFFSETFROM:+0200\r\nTZOFFSETTO:+0100\r\nTZNAME:CET\r\nRRULE:FREQ=YEARLY;BYMONTH=10;BYDAY=-1SU",
+	} {
+		if !strings.Contains(body, line+"\r\n") {
+			t.Errorf("calendar is missing %q:\n%s", line, body)
+		}
+	}
+}
+
+func TestICalTimezoneWithoutDaylightSaving(t *testing.T) {
+	want := []string{"BEGIN:VTIMEZONE", "TZID:UTC", "BEGIN:STANDARD", "DTSTART:19700101T000000", "TZOFFSETFROM:+0000", "TZOFFSETTO:+0000", "TZNAME:UTC", "END:STANDARD", "END:VTIMEZONE"}
+	if got := icalTimezone(time.UTC, 2023); !slices.Equal(got, want) {
+		t.Errorf("icalTimezone(UTC) = %q, want %q", got, want)
+	}
+}
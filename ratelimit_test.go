@@ -0,0 +1,123 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	config := testConfig(t)
+	config.RateLimitPerMinute = 60
+	config.RateLimitBurst = 5
+	s, clock := newTestServer(t, config, staticState(LabState{Open: Pointer(true)}))
+	handler := s.Handler()
+	request := func(remoteAddr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/v15", nil)
+		r.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	for i := range config.RateLimitBurst {
+		if w := request("192.0.2.1:1234"); w.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d, want %d within the burst", i, w.Code, http.StatusOK)
+		}
+	}
+	for i := range 20 {
+		w := request("192.0.2.1:1234")
+		if w.Code != http.StatusTooManyRequests {
+			t.Fatalf("request %d after the burst: status = %d, want %d", i, w.Code, http.StatusTooManyRequests)
+		}
+		if retryAfter := w.Header().Get("Retry-After"); retryAfter != "1" {
+			t.Errorf("Retry-After = %q, want 1", retryAfter)
+		}
+	}
+	if w := request("192.0.2.2:1234"); w.Code != http.StatusOK {
+		t.Errorf("other client: status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	clock.Advance(time.Second)
+	if w := request("192.0.2.1:1234"); w.Code != http.StatusOK {
+		t.Errorf("after a second: status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if w := request("192.0.2.1:1234"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("after a second and another request: status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+}
+
+func TestRateLimitExempt(t *testing.T) {
+	config := testConfig(t)
+	config.RateLimitPerMinute = 60
+	config.RateLimitBurst = 1
+	s, _ := newTestServer(t, config, staticState(LabState{Open: Pointer(true)}))
+	handler := s.Handler()
+	for _, target := range []string{"/healthz", "/readyz", "/metrics"} {
+		for i := range 10 {
+			r := httptest.NewRequest("GET", target, nil)
+			r.RemoteAddr = "192.0.2.1:1234"
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code == http.StatusTooManyRequests {
+				t.Fatalf("%s request %d: status = %d, want monitoring never limited", target, i, w.Code)
+			}
+		}
+	}
+}
+
+func TestRateLimitDisabled(t *testing.T) {
+	config := testConfig(t)
+	s, _ := newTestServer(t, config, staticState(LabState{Open: Pointer(true)}))
+	for i := range 100 {
+		if w := serve(s, "GET", "/v15", nil); w.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d, want %d", i, w.Code, http.StatusOK)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trusted, err := parseTrustedProxies([]string{"10.0.0.0/8", "2001:db8::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		remoteAddr string
+		forwarded  string
+		want       string
+	}{
+		{"192.0.2.1:1234", "", "192.0.2.1"},
+		{"192.0.2.1:1234", "198.51.100.7", "192.0.2.1"},
+		{"10.0.0.1:1234", "198.51.100.7", "198.51.100.7"},
+		{"10.0.0.1:1234", "203.0.113.9, 198.51.100.7, 10.0.0.2", "198.51.100.7"},
+		{"10.0.0.1:1234", "10.0.0.3, 10.0.0.2", "10.0.0.3"},
+		{"10.0.0.1:1234", "", "10.0.0.1"},
+		{"[2001:db8::1]:1234", "198.51.100.7", "198.51.100.7"},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/v15", nil)
+		r.RemoteAddr = test.remoteAddr
+		if test.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", test.forwarded)
+		}
+		if got := clientIP(r, trusted); got != test.want {
+			t.Errorf("clientIP(%s, X-Forwarded-For %q) = %s, want %s", test.remoteAddr, test.forwarded, got, test.want)
+		}
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	prefixes, err := parseTrustedProxies([]string{"10.1.2.3/8", "192.0.2.1"})
+	if err != nil {
+		t.Fatalf("parseTrustedProxies() = %v", err)
+	}
+	want := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("192.0.2.1/32")}
+	if len(prefixes) != len(want) || prefixes[0] != want[0] || prefixes[1] != want[1] {
+		t.Errorf("parseTrustedProxies() = %v, want %v", prefixes, want)
+	}
+	if _, err := parseTrustedProxies([]string{"proxy.local"}); err == nil {
+		t.Error("parseTrustedProxies(proxy.local) succeeded, want an error")
+	}
+}
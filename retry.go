@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRetryAfter caps how long an upstream can ask us to back off, so a bogus Retry-After can't stop all fetches
+const maxRetryAfter = time.Hour
+
+// statusError is returned when the state api answers with a non-2xx status
+type statusError struct {
+	StatusCode int
+	RetryAfter time.Time // zero unless the state api asked to back off until then
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("state api returned status %d (%s)", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// isRetryable reports whether a failed request is worth retrying: network errors and 5xx responses are,
+// client errors, unparseable responses and an exhausted overall deadline are not
+func isRetryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500 && statusErr.RetryAfter.IsZero()
+	}
+	var netErr interface{ Timeout() bool }
+	return errors.As(err, &netErr)
+}
+
+// retryDelay returns the exponential backoff delay before the given retry, plus a random jitter
+func retryDelay(retry int, base, jitter time.Duration) time.Duration {
+	delay := base << (retry - 1)
+	if jitter > 0 {
+		delay += rand.N(jitter)
+	}
+	return delay
+}
+
+// parseRetryAfter parses a Retry-After header given either in seconds or as an HTTP date, ok is false if it is
+// missing or invalid, the result is capped at maxRetryAfter from now
+func parseRetryAfter(value string, now time.Time) (until time.Time, ok bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return time.Time{}, false
+		}
+		until = now.Add(time.Duration(seconds) * time.Second)
+	} else if date, err := http.ParseTime(value); err == nil {
+		until = date
+	} else {
+		return time.Time{}, false
+	}
+	if limit := now.Add(maxRetryAfter); until.After(limit) {
+		until = limit
+	}
+	return until, true
+}
+
+// throttledError is returned instead of fetching while an upstream asked us to back off
+type throttledError struct {
+	Until time.Time
+}
+
+func (e *throttledError) Error() string {
+	return fmt.Sprintf("upstream asked to back off until %s", e.Until.Format(time.RFC3339))
+}
+
+// throttle remembers until when upstreams asked not to be requested again
+type throttle struct {
+	mu    sync.Mutex
+	until map[string]time.Time
+}
+
+func newThrottle() *throttle {
+	return &throttle{until: make(map[string]time.Time)}
+}
+
+// set suppresses requests to url until the given time
+func (t *throttle) set(url string, until time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.until[url] = until
+}
+
+// get returns until when requests to url are suppressed, ok is false if they aren't
+func (t *throttle) get(url string, now time.Time) (until time.Time, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	until, ok = t.until[url]
+	if ok && !now.Before(until) {
+		delete(t.until, url)
+		return time.Time{}, false
+	}
+	return until, ok
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"iter"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// icalWeekdays maps the weekday codes of BYDAY to weekdays
+var icalWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// recurrenceRule is an iCalendar RRULE, only daily and weekly rules are supported
+type recurrenceRule struct {
+	freq     string // DAILY or WEEKLY
+	interval int
+	count    int       // zero if the number of occurrences isn't limited
+	until    time.Time // zero if the last occurrence isn't limited
+	byDay    []time.Weekday
+}
+
+// parseRRule parses the value of an RRULE, a date-only UNTIL includes the whole day in loc
+func parseRRule(value string, loc *time.Location) (*recurrenceRule, error) {
+	rule := &recurrenceRule{interval: 1}
+	for _, part := range strings.Split(value, ";") {
+		key, v, _ := strings.Cut(part, "=")
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			rule.freq = strings.ToUpper(v)
+			if rule.freq != "DAILY" && rule.freq != "WEEKLY" {
+				return nil, fmt.Errorf("unsupported recurrence frequency %q", v)
+			}
+		case "INTERVAL":
+			interval, err := strconv.Atoi(v)
+			if err != nil || interval < 1 {
+				return nil, fmt.Errorf("invalid recurrence interval %q", v)
+			}
+			rule.interval = interval
+		case "COUNT":
+			count, err := strconv.Atoi(v)
+			if err != nil || count < 1 {
+				return nil, fmt.Errorf("invalid recurrence count %q", v)
+			}
+			rule.count = count
+		case "UNTIL":
+			until, err := parseICalTime(v, nil, loc)
+			if err != nil {
+				return nil, fmt.Errorf("invalid recurrence end %q: %w", v, err)
+			}
+			if len(v) == 8 {
+				until = until.AddDate(0, 0, 1).Add(-time.Nanosecond)
+			}
+			rule.until = until
+		case "BYDAY":
+			for _, day := range strings.Split(v, ",") {
+				weekday, ok := icalWeekdays[strings.ToUpper(day)]
+				if !ok {
+					return nil, fmt.Errorf("unsupported recurrence weekday %q", day)
+				}
+				rule.byDay = append(rule.byDay, weekday)
+			}
+		case "WKST":
+			//weeks always start on monday, which only matters for weekly rules with an interval and several weekdays
+		default:
+			return nil, fmt.Errorf("unsupported recurrence rule part %q", part)
+		}
+	}
+	if rule.freq == "" {
+		return nil, fmt.Errorf("recurrence rule %q has no frequency", value)
+	}
+	return rule, nil
+}
+
+// occurrences returns the occurrences of the rule for a series starting at start in the order they occur, the first
+// one is the first matching the rule, not necessarily start, times of day are kept across daylight saving changes
+func (rule *recurrenceRule) occurrences(start time.Time) iter.Seq[time.Time] {
+	return func(yield func(time.Time) bool) {
+		count := 0
+		matched := 0
+		for period := 0; ; period++ {
+			//a daily rule whose interval never lands on one of its weekdays has no occurrences
+			if period > matched+7 {
+				return
+			}
+			for _, t := range rule.candidates(start, period) {
+				if t.Before(start) || (len(rule.byDay) > 0 && !slices.Contains(rule.byDay, t.Weekday())) {
+					continue
+				}
+				if (!rule.until.IsZero() && t.After(rule.until)) || (rule.count > 0 && count == rule.count) {
+					return
+				}
+				count++
+				matched = period
+				if !yield(t) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// candidates returns the times of the nth period of the series, before they are filtered by weekday
+func (rule *recurrenceRule) candidates(start time.Time, n int) []time.Time {
+	if rule.freq == "DAILY" {
+		return []time.Time{start.AddDate(0, 0, n*rule.interval)}
+	}
+	if len(rule.byDay) == 0 {
+		return []time.Time{start.AddDate(0, 0, 7*n*rule.interval)}
+	}
+	monday := start.AddDate(0, 0, 7*n*rule.interval-(int(start.Weekday())+6)%7)
+	days := make([]time.Time, 7)
+	for i := range days {
+		days[i] = monday.AddDate(0, 0, i)
+	}
+	return days
+}
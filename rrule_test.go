@@ -0,0 +1,71 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseRRuleInvalid(t *testing.T) {
+	for _, value := range []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=MONTHLY",
+		"FREQ=WEEKLY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=x",
+		"FREQ=WEEKLY;UNTIL=tomorrow",
+		"FREQ=WEEKLY;BYDAY=1FR",
+		"FREQ=WEEKLY;BYMONTH=3",
+	} {
+		if _, err := parseRRule(value, time.UTC); err == nil {
+			t.Errorf("parseRRule(%q) succeeded, want an error", value)
+		}
+	}
+}
+
+func TestRecurrenceOccurrences(t *testing.T) {
+	vienna, err := time.LoadLocation("Europe/Vienna")
+	if err != nil {
+		t.Fatal(err)
+	}
+	date := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2024, month, day, hour, 0, 0, 0, vienna)
+	}
+	tests := []struct {
+		rrule string
+		start time.Time
+		want  []time.Time
+	}{
+		{"FREQ=DAILY;COUNT=3", date(3, 1, 19), []time.Time{date(3, 1, 19), date(3, 2, 19), date(3, 3, 19)}},
+		{"FREQ=DAILY;INTERVAL=3;COUNT=3", date(3, 1, 19), []time.Time{date(3, 1, 19), date(3, 4, 19), date(3, 7, 19)}},
+		//the first occurrence is the first matching the rule, which starts on a thursday
+		{"FREQ=WEEKLY;BYDAY=FR;COUNT=2", date(2, 29, 19), []time.Time{date(3, 1, 19), date(3, 8, 19)}},
+		{"FREQ=WEEKLY;BYDAY=TU,FR;COUNT=4", date(2, 29, 19), []time.Time{date(3, 1, 19), date(3, 5, 19), date(3, 8, 19), date(3, 12, 19)}},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR;COUNT=3", date(3, 4, 19), []time.Time{date(3, 4, 19), date(3, 8, 19), date(3, 18, 19)}},
+		{"FREQ=DAILY;BYDAY=SA,SU;COUNT=3", date(3, 1, 10), []time.Time{date(3, 2, 10), date(3, 3, 10), date(3, 9, 10)}},
+		//the time of day is kept across the change to daylight saving time on march 31st
+		{"FREQ=WEEKLY;COUNT=2", date(3, 27, 19), []time.Time{date(3, 27, 19), date(4, 3, 19)}},
+		//a date-only end includes the whole day
+		{"FREQ=DAILY;UNTIL=20240303", date(3, 1, 19), []time.Time{date(3, 1, 19), date(3, 2, 19), date(3, 3, 19)}},
+		{"FREQ=DAILY;UNTIL=20240302T180000Z", date(3, 1, 19), []time.Time{date(3, 1, 19), date(3, 2, 19)}},
+		//every seventh day is always the same weekday
+		{"FREQ=DAILY;INTERVAL=7;BYDAY=MO", date(3, 1, 19), nil},
+	}
+	for _, test := range tests {
+		rule, err := parseRRule(test.rrule, vienna)
+		if err != nil {
+			t.Errorf("parseRRule(%q): %v", test.rrule, err)
+			continue
+		}
+		var got []time.Time
+		for start := range rule.occurrences(test.start) {
+			if len(got) == 10 {
+				break
+			}
+			got = append(got, start)
+		}
+		if !slices.EqualFunc(got, test.want, time.Time.Equal) {
+			t.Errorf("%s from %s = %v, want %v", test.rrule, test.start, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+//go:embed 15.json
+var spaceApiSchemaJSON []byte
+
+const spaceApiSchemaURL = "https://schema.spaceapi.io/15.json"
+
+var spaceApiSchema = mustCompileSchema()
+
+// mustCompileSchema compiles the embedded SpaceAPI v15 schema, it panics if the embedded schema is broken
+func mustCompileSchema() *jsonschema.Schema {
+	schema, err := jsonschema.UnmarshalJSON(bytes.NewReader(spaceApiSchemaJSON))
+	if err != nil {
+		panic(fmt.Sprintf("invalid embedded SpaceAPI schema: %v", err))
+	}
+
+	c := jsonschema.NewCompiler()
+	if err := c.AddResource(spaceApiSchemaURL, schema); err != nil {
+		panic(fmt.Sprintf("invalid embedded SpaceAPI schema: %v", err))
+	}
+	return c.MustCompile(spaceApiSchemaURL)
+}
+
+// schemaViolations validates the document against the SpaceAPI v15 schema and returns every violation found
+func schemaViolations(doc *SpaceAPIv15) ([]string, error) {
+	p, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(p))
+	if err != nil {
+		return nil, err
+	}
+	err = spaceApiSchema.Validate(instance)
+	var validationErr *jsonschema.ValidationError
+	if errors.As(err, &validationErr) {
+		var violations []string
+		for _, unit := range validationErr.BasicOutput().Errors {
+			if unit.Error != nil {
+				violations = append(violations, fmt.Sprintf("%s: %s", unit.InstanceLocation, unit.Error))
+			}
+		}
+		return violations, nil
+	}
+	return nil, err
+}
+
+// validateStaticDocument checks the static parts of the document against the schema,
+// the lab state is only known at runtime so a placeholder is used for it
+func validateStaticDocument(doc *SpaceAPIv15) ([]string, error) {
+	static := *doc
+	if static.State != nil {
+		state := *static.State
+		state.Open = Pointer(false)
+		static.State = &state
+	}
+	return schemaViolations(&static)
+}
+
+// ValidationResult is the response body of the validation endpoint
+type ValidationResult struct {
+	Valid      bool     `json:"valid"`
+	Violations []string `json:"violations,omitempty"`
+}
+
+// handleValidate assembles the document with a freshly fetched lab state and validates it against the schema,
+// so changes of the upstream formats that break the document are noticed
+func (s *Server) handleValidate(w http.ResponseWriter, r *http.Request) {
+	//the document falls back to the last known state if this fails, which is validated instead
+	if _, err := s.refreshLabState(r.Context()); err != nil {
+		s.logger.Warn("lab state unavailable, validating the last known state", "error", err)
+	}
+
+	violations, err := schemaViolations(s.currentSpaceApiDocument(w, r))
+	if err != nil {
+		s.logger.Error("error while validating space document", "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	p, _ := json.Marshal(ValidationResult{Valid: len(violations) == 0, Violations: violations})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if len(violations) > 0 {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	w.Write(p)
+}
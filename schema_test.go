@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestValidateStaticDocument(t *testing.T) {
+	violations, err := validateStaticDocument(defaultSpaceDocument())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(violations) > 0 {
+		t.Errorf("default document violates the schema: %v", violations)
+	}
+
+	doc := defaultSpaceDocument()
+	doc.Logo = ""
+	violations, err = validateStaticDocument(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(violations) != 1 || !strings.Contains(violations[0], "logo") {
+		t.Errorf("violations = %v, want the missing logo", violations)
+	}
+}
+
+func TestStrictValidation(t *testing.T) {
+	invalid := defaultSpaceDocument()
+	invalid.Logo = ""
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	config := testConfig(t)
+	config.StrictValidation = true
+	if _, err := NewServer(WithConfig(config), WithDocument(invalid), WithLogger(logger)); err == nil {
+		t.Error("NewServer() with an invalid document succeeded, want an error with strict validation")
+	}
+
+	invalid = defaultSpaceDocument()
+	invalid.Logo = ""
+	config.StrictValidation = false
+	if _, err := NewServer(WithConfig(config), WithDocument(invalid), WithLogger(logger)); err != nil {
+		t.Errorf("NewServer() = %v, want only warnings without strict validation", err)
+	}
+}
+
+func TestUnknownStateOpenNull(t *testing.T) {
+	s, _ := newTestServer(t, testConfig(t), staticState(LabState{}))
+	for _, test := range []struct {
+		target  string
+		present bool
+	}{
+		{"/v15", false},
+		{"/v14", false},
+		{"/v13", true},
+	} {
+		doc := decodeJSON[struct {
+			State map[string]json.RawMessage `json:"state"`
+		}](t, serve(s, "GET", test.target, nil))
+		open, ok := doc.State["open"]
+		if ok != test.present || (ok && string(open) != "null") {
+			t.Errorf("%s: state.open = %s (present %v), want present %v and null", test.target, open, ok, test.present)
+		}
+	}
+}
+
+func TestValidateEndpoint(t *testing.T) {
+	s, _ := newTestServer(t, testConfig(t), staticState(LabState{Open: Pointer(true)}))
+	w := serve(s, "GET", "/v15/validate", nil)
+	if result := decodeJSON[ValidationResult](t, w); w.Code != http.StatusOK || !result.Valid {
+		t.Errorf("status = %d, result = %+v, want a valid document", w.Code, result)
+	}
+
+	//an unknown state leaves state.open out, as the schema expects
+	s, _ = newTestServer(t, testConfig(t), staticState(LabState{}))
+	w = serve(s, "GET", "/v15/validate", nil)
+	if result := decodeJSON[ValidationResult](t, w); w.Code != http.StatusOK || !result.Valid {
+		t.Errorf("status = %d, result = %+v, want a valid document", w.Code, result)
+	}
+}
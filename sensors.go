@@ -0,0 +1,506 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// SensorReading is a single reading as reported by a sensor source
+type SensorReading struct {
+	Location    string  `json:"location"`
+	Name        string  `json:"name,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Value       float64 `json:"value"`
+	Unit        string  `json:"unit,omitempty"`
+	Timestamp   int64   `json:"timestamp,omitempty"`
+}
+
+// baseSensor returns the common sensor fields of the reading, fetchedAt is used as the last change
+// if the source doesn't report when the reading was taken
+func (r SensorReading) baseSensor(fetchedAt time.Time) BaseSensor {
+	return BaseSensor{
+		Location:    r.Location,
+		Name:        r.Name,
+		Description: r.Description,
+		LastChange:  lastChange(r.Timestamp, fetchedAt),
+	}
+}
+
+// lastChange returns the unix timestamp reported by a source, or fetchedAt if there is none
+func lastChange(timestamp int64, fetchedAt time.Time) int64 {
+	if timestamp != 0 {
+		return timestamp
+	}
+	return fetchedAt.Unix()
+}
+
+// valueCache holds the last successfully fetched value of a source for a limited time
+type valueCache[T any] struct {
+	mu        sync.RWMutex
+	name      string // name of the source, used as label of the cache metrics
+	ttl       time.Duration
+	maxStale  time.Duration // how long the value is served after it expired and fetching a new one failed, zero is forever
+	value     T
+	fetchedAt time.Time
+	expiresAt time.Time
+	lastErr   error
+	clock     Clock
+	jitter    func() time.Duration // extra time added to the ttl of every fetched value, may be nil
+	fetches   singleflight.Group
+}
+
+// getOrFetch returns the cached value if it is still fresh, otherwise it calls fetch,
+// if fetch fails or ctx is done first the last known value is returned, ok is false if there is none that may be served
+func (c *valueCache[T]) getOrFetch(ctx context.Context, fetch func() (T, error)) (value T, ok bool) {
+	c.mu.RLock()
+	value, fetchedAt, expiresAt := c.value, c.fetchedAt, c.expiresAt
+	c.mu.RUnlock()
+
+	if !fetchedAt.IsZero() && !c.clock.Now().After(expiresAt) {
+		cacheHitsTotal.WithLabelValues(c.name).Inc()
+		return value, true
+	}
+	cacheMissesTotal.WithLabelValues(c.name).Inc()
+
+	//concurrent requests finding the cache expired share a single fetch, which goes on and fills the cache
+	//even if all of them stopped waiting for it
+	result := c.fetches.DoChan("", func() (any, error) {
+		fresh, err := fetch()
+		c.store(fresh, err)
+		return fresh, err
+	})
+	select {
+	case result := <-result:
+		if result.Err == nil {
+			return result.Val.(T), true
+		}
+	case <-ctx.Done():
+	}
+	if !c.servable(fetchedAt) {
+		var zero T
+		return zero, false
+	}
+	return value, true
+}
+
+// servable reports whether a value fetched at fetchedAt may still be served because a new one couldn't be fetched
+func (c *valueCache[T]) servable(fetchedAt time.Time) bool {
+	if fetchedAt.IsZero() {
+		return false
+	}
+	return c.maxStale <= 0 || !c.clock.Now().After(fetchedAt.Add(c.maxStale))
+}
+
+// store records the outcome of a fetch, a failed fetch keeps the last known value
+func (c *valueCache[T]) store(fresh T, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err != nil {
+		c.lastErr = err
+		return
+	}
+	c.value = fresh
+	c.fetchedAt = c.clock.Now()
+	c.expiresAt = c.fetchedAt.Add(c.ttl)
+	if c.jitter != nil {
+		c.expiresAt = c.expiresAt.Add(c.jitter())
+	}
+	c.lastErr = nil
+}
+
+// expire makes the next getOrFetch fetch a new value, the cached one is kept in case that fails
+func (c *valueCache[T]) expire() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.expiresAt = time.Time{}
+}
+
+// status returns the error of the last fetch (nil if it succeeded) and the time of the last successful fetch
+func (c *valueCache[T]) status() (lastErr error, lastSuccess time.Time) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.lastErr, c.fetchedAt
+}
+
+// fetchTemperatureSensors fetches the temperature readings from the configured source
+func (s *Server) fetchTemperatureSensors() ([]TempSensor, error) {
+	var readings []SensorReading
+	if err := s.fetchJSON(s.config.TemperatureSensorsURL, &readings); err != nil {
+		s.logger.Warn("temperature sensors unavailable", "url", s.config.TemperatureSensorsURL, "error", err)
+		return nil, err
+	}
+	fetchedAt := s.clock.Now()
+
+	sensors := make([]TempSensor, 0, len(readings))
+	for _, reading := range readings {
+		unit := reading.Unit
+		if unit == "" {
+			unit = "°C"
+		}
+		value, err := convertTemperature(reading.Value, unit, s.config.TemperatureUnit)
+		if err != nil {
+			s.logger.Warn("dropping temperature reading", "location", reading.Location, "error", err)
+			continue
+		}
+		sensors = append(sensors, TempSensor{
+			BaseSensor: reading.baseSensor(fetchedAt),
+			Value:      value,
+			Unit:       s.config.TemperatureUnit,
+		})
+	}
+	return sensors, nil
+}
+
+// validTemperatureUnit reports whether unit is a supported temperature unit
+func validTemperatureUnit(unit string) bool {
+	return unit == "°C" || unit == "°F" || unit == "K"
+}
+
+// convertTemperature converts value from one temperature unit to another, rounded to two decimal places
+func convertTemperature(value float64, from, to string) (float64, error) {
+	if !validTemperatureUnit(from) {
+		return 0, fmt.Errorf("unknown temperature unit %q", from)
+	}
+	if from == to {
+		return value, nil
+	}
+
+	//convert via celsius
+	celsius := value
+	switch from {
+	case "°F":
+		celsius = (value - 32) * 5 / 9
+	case "K":
+		celsius = value - 273.15
+	}
+	converted := celsius
+	switch to {
+	case "°F":
+		converted = celsius*9/5 + 32
+	case "K":
+		converted = celsius + 273.15
+	}
+	return math.Round(converted*100) / 100, nil
+}
+
+// fetchCO2Sensors fetches the CO2 readings from the configured source
+func (s *Server) fetchCO2Sensors() ([]CO2Sensor, error) {
+	var readings []SensorReading
+	if err := s.fetchJSON(s.config.CO2SensorsURL, &readings); err != nil {
+		s.logger.Warn("co2 sensors unavailable", "url", s.config.CO2SensorsURL, "error", err)
+		return nil, err
+	}
+	fetchedAt := s.clock.Now()
+
+	sensors := make([]CO2Sensor, 0, len(readings))
+	for _, reading := range readings {
+		sensors = append(sensors, CO2Sensor{
+			BaseSensor: reading.baseSensor(fetchedAt),
+			Value:      reading.Value,
+			Unit:       "ppm",
+		})
+	}
+	return sensors, nil
+}
+
+// freshCO2Sensors returns the readings taken within the configured max age, it is applied when the readings are served
+// as they age in the cache as well
+func (s *Server) freshCO2Sensors(sensors []CO2Sensor) []CO2Sensor {
+	now := s.clock.Now()
+	fresh := make([]CO2Sensor, 0, len(sensors))
+	for _, sensor := range sensors {
+		if now.Sub(time.Unix(sensor.LastChange, 0)) > s.config.CO2MaxAge {
+			s.logger.Debug("dropping stale co2 reading", "location", sensor.Location, "last_change", sensor.LastChange)
+			continue
+		}
+		fresh = append(fresh, sensor)
+	}
+	return fresh
+}
+
+// fetchHumiditySensors fetches the relative humidity readings from the configured source,
+// readings outside of 0-100% are clamped
+func (s *Server) fetchHumiditySensors() ([]HumiditySensor, error) {
+	var readings []SensorReading
+	if err := s.fetchJSON(s.config.HumiditySensorsURL, &readings); err != nil {
+		s.logger.Warn("humidity sensors unavailable", "url", s.config.HumiditySensorsURL, "error", err)
+		return nil, err
+	}
+	fetchedAt := s.clock.Now()
+
+	sensors := make([]HumiditySensor, 0, len(readings))
+	for _, reading := range readings {
+		value := min(max(reading.Value, 0), 100)
+		if value != reading.Value {
+			s.logger.Warn("humidity reading out of range, clamping", "location", reading.Location, "value", reading.Value)
+		}
+		sensors = append(sensors, HumiditySensor{
+			BaseSensor: reading.baseSensor(fetchedAt),
+			Value:      value,
+			Unit:       "%",
+		})
+	}
+	return sensors, nil
+}
+
+// fetchBarometerSensors fetches the barometric pressure readings from the configured source, converted to hPa
+func (s *Server) fetchBarometerSensors() ([]BarometerSensor, error) {
+	var readings []SensorReading
+	if err := s.fetchJSON(s.config.BarometerSensorsURL, &readings); err != nil {
+		s.logger.Warn("barometer sensors unavailable", "url", s.config.BarometerSensorsURL, "error", err)
+		return nil, err
+	}
+	fetchedAt := s.clock.Now()
+
+	sensors := make([]BarometerSensor, 0, len(readings))
+	for _, reading := range readings {
+		unit := reading.Unit
+		if unit == "" {
+			unit = "hPa"
+		}
+		value, err := convertPressureToHectopascal(reading.Value, unit)
+		if err != nil {
+			s.logger.Warn("dropping barometer reading", "location", reading.Location, "error", err)
+			continue
+		}
+		sensors = append(sensors, BarometerSensor{
+			BaseSensor: reading.baseSensor(fetchedAt),
+			Value:      value,
+			Unit:       "hPa",
+		})
+	}
+	return sensors, nil
+}
+
+// convertPressureToHectopascal converts a pressure given in Pa, hPa or mbar to hPa, rounded to two decimal places
+func convertPressureToHectopascal(value float64, unit string) (float64, error) {
+	switch unit {
+	case "hPa", "mbar":
+		//one millibar is exactly one hectopascal
+		return value, nil
+	case "Pa":
+		return math.Round(value) / 100, nil
+	default:
+		return 0, fmt.Errorf("unknown pressure unit %q", unit)
+	}
+}
+
+// RadiationReading is a single reading as reported by a radiation sensor source
+type RadiationReading struct {
+	SensorReading
+	Type             string  `json:"type,omitempty"` // alpha, beta, gamma or beta_gamma, may be omitted if the source provides a single type
+	DeadTime         float64 `json:"dead_time,omitempty"`
+	ConversionFactor float64 `json:"conversion_factor,omitempty"`
+}
+
+// fetchRadiationSensors fetches the radiation readings from the configured source and sorts them by radiation type
+func (s *Server) fetchRadiationSensors() (*RadiationSensors, error) {
+	var readings []RadiationReading
+	if err := s.fetchJSON(s.config.RadiationSensorsURL, &readings); err != nil {
+		s.logger.Warn("radiation sensors unavailable", "url", s.config.RadiationSensorsURL, "error", err)
+		return nil, err
+	}
+	return s.sortRadiationReadings(readings, s.config.RadiationTypes, s.clock.Now()), nil
+}
+
+// sortRadiationReadings places every reading into the slice of its radiation type, readings without a type
+// are assigned the only provided type, readings of a type that isn't provided are dropped
+func (s *Server) sortRadiationReadings(readings []RadiationReading, types []string, fetchedAt time.Time) *RadiationSensors {
+	radiation := &RadiationSensors{}
+	for _, reading := range readings {
+		radiationType := reading.Type
+		if radiationType == "" && len(types) == 1 {
+			radiationType = types[0]
+		}
+		if !slices.Contains(types, radiationType) {
+			s.logger.Warn("dropping radiation reading of unexpected type", "location", reading.Location, "type", reading.Type)
+			continue
+		}
+
+		unit := reading.Unit
+		if unit == "" {
+			unit = "cpm"
+		}
+		sensor := RadiationSensor{
+			BaseSensor:       reading.baseSensor(fetchedAt),
+			Value:            reading.Value,
+			Unit:             unit,
+			DeadTime:         reading.DeadTime,
+			ConversionFactor: reading.ConversionFactor,
+		}
+		switch radiationType {
+		case "alpha":
+			radiation.Alpha = append(radiation.Alpha, sensor)
+		case "beta":
+			radiation.Beta = append(radiation.Beta, sensor)
+		case "gamma":
+			radiation.Gamma = append(radiation.Gamma, sensor)
+		case "beta_gamma":
+			radiation.BetaGamma = append(radiation.BetaGamma, sensor)
+		}
+	}
+	return radiation
+}
+
+// validRadiationType reports whether t is one of the radiation types of the SpaceAPI
+func validRadiationType(t string) bool {
+	return t == "alpha" || t == "beta" || t == "gamma" || t == "beta_gamma"
+}
+
+// DoorLockStatus is the response of the door lock api
+type DoorLockStatus struct {
+	Locked    bool  `json:"locked"`
+	Timestamp int64 `json:"timestamp,omitempty"`
+}
+
+// fetchDoorLock fetches the lock state of the front door, independently of the open state
+func (s *Server) fetchDoorLock() ([]DoorSensor, error) {
+	var status DoorLockStatus
+	if err := s.fetchJSON(s.config.DoorLockURL, &status); err != nil {
+		s.logger.Warn("door lock state unavailable", "url", s.config.DoorLockURL, "error", err)
+		return nil, err
+	}
+
+	return []DoorSensor{{
+		BaseSensor: BaseSensor{Location: "front door", LastChange: lastChange(status.Timestamp, s.clock.Now())},
+		Value:      status.Locked,
+	}}, nil
+}
+
+// fetchBeverageSupply fetches the stock of each beverage from the configured source
+func (s *Server) fetchBeverageSupply() ([]BeverageSensor, error) {
+	var readings []SensorReading
+	if err := s.fetchJSON(s.config.BeverageSupplyURL, &readings); err != nil {
+		s.logger.Warn("beverage supply unavailable", "url", s.config.BeverageSupplyURL, "error", err)
+		return nil, err
+	}
+	fetchedAt := s.clock.Now()
+
+	sensors := make([]BeverageSensor, 0, len(readings))
+	for _, reading := range readings {
+		sensors = append(sensors, BeverageSensor{
+			BaseSensor: reading.baseSensor(fetchedAt),
+			Value:      reading.Value,
+			Unit:       "bottle",
+		})
+	}
+	return sensors, nil
+}
+
+// expireSensorCaches makes the next currentSensors fetch every sensor source anew
+func (s *Server) expireSensorCaches() {
+	s.temperatureCache.expire()
+	s.co2Cache.expire()
+	s.humidityCache.expire()
+	s.barometerCache.expire()
+	s.radiationCache.expire()
+	s.doorLockCache.expire()
+	s.beverageSupplyCache.expire()
+}
+
+// currentSensors returns the static sensor block merged with the fetched sensor data, or nil if there is none,
+// sources that didn't answer before ctx is done are served from cache
+func (s *Server) currentSensors(ctx context.Context, static *Sensors) *Sensors {
+	sensors := &Sensors{}
+	if static != nil {
+		*sensors = *static
+	}
+
+	//the sources are fetched concurrently, each one bounded by its own timeout, so a slow or failing source
+	//doesn't hold up the others, every goroutine only writes its own field
+	var wg sync.WaitGroup
+	fetch := func(url string, merge func()) {
+		if url == "" {
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			merge()
+		}()
+	}
+
+	fetch(s.config.TemperatureSensorsURL, func() {
+		if temperature, ok := s.temperatureCache.getOrFetch(ctx, s.fetchTemperatureSensors); ok && len(temperature) > 0 {
+			sensors.Temperature = temperature
+		}
+	})
+	fetch(s.config.CO2SensorsURL, func() {
+		if co2, ok := s.co2Cache.getOrFetch(ctx, s.fetchCO2Sensors); ok {
+			if co2 = s.freshCO2Sensors(co2); len(co2) > 0 {
+				sensors.CarbonDioxide = co2
+			}
+		}
+	})
+	fetch(s.config.HumiditySensorsURL, func() {
+		if humidity, ok := s.humidityCache.getOrFetch(ctx, s.fetchHumiditySensors); ok && len(humidity) > 0 {
+			sensors.Humidity = humidity
+		}
+	})
+	fetch(s.config.BarometerSensorsURL, func() {
+		if barometer, ok := s.barometerCache.getOrFetch(ctx, s.fetchBarometerSensors); ok && len(barometer) > 0 {
+			sensors.Barometer = barometer
+		}
+	})
+	fetch(s.config.RadiationSensorsURL, func() {
+		if radiation, ok := s.radiationCache.getOrFetch(ctx, s.fetchRadiationSensors); ok && !radiation.empty() {
+			sensors.Radiation = radiation
+		}
+	})
+	fetch(s.config.DoorLockURL, func() {
+		if doorLocked, ok := s.doorLockCache.getOrFetch(ctx, s.fetchDoorLock); ok {
+			sensors.DoorLocked = doorLocked
+		}
+	})
+	fetch(s.config.BeverageSupplyURL, func() {
+		if beverages, ok := s.beverageSupplyCache.getOrFetch(ctx, s.fetchBeverageSupply); ok && len(beverages) > 0 {
+			sensors.BeverageSupply = beverages
+		}
+	})
+	wg.Wait()
+
+	s.mergeMQTTSensors(sensors)
+
+	if sensors.empty() {
+		return nil
+	}
+	return sensors
+}
+
+// empty reports whether the radiation block holds no readings of any type
+func (r *RadiationSensors) empty() bool {
+	return len(r.Alpha) == 0 && len(r.Beta) == 0 && len(r.Gamma) == 0 && len(r.BetaGamma) == 0
+}
+
+// empty reports whether the sensor block holds no data at all
+func (s *Sensors) empty() bool {
+	return len(s.Temperature) == 0 &&
+		len(s.CarbonDioxide) == 0 &&
+		len(s.DoorLocked) == 0 &&
+		len(s.Barometer) == 0 &&
+		s.Radiation == nil &&
+		len(s.Humidity) == 0 &&
+		len(s.BeverageSupply) == 0
+}
+
+// handleSensors serves only the sensor block of the SpaceAPI document
+func (s *Server) handleSensors(w http.ResponseWriter, r *http.Request) {
+	s.setCacheControl(w)
+	ctx, cancel := s.upstreamContext(r)
+	defer cancel()
+	sensors := s.currentSensors(ctx, s.doc.Sensors)
+	if sensors == nil {
+		sensors = &Sensors{}
+	}
+	s.writeJSON(w, r, sensors)
+}
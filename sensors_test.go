@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newSensorSource serves body as a sensor source, until the returned flag is set and it fails
+func newSensorSource(t *testing.T, body string) (url string, failing *atomic.Bool) {
+	t.Helper()
+	failing = &atomic.Bool{}
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			http.Error(w, "sensor offline", http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(source.Close)
+	return source.URL, failing
+}
+
+func TestValueCacheTTL(t *testing.T) {
+	clock := newFakeClock(time.Unix(1700000000, 0))
+	cache := &valueCache[int]{name: "test", ttl: time.Minute, clock: clock}
+	fetches := 0
+	fetch := func() (int, error) {
+		fetches++
+		return fetches, nil
+	}
+
+	for range 2 {
+		if value, ok := cache.getOrFetch(context.Background(), fetch); !ok || value != 1 {
+			t.Errorf("getOrFetch() = %d, %v, want 1, true", value, ok)
+		}
+	}
+	clock.Advance(time.Minute + time.Second)
+	if value, ok := cache.getOrFetch(context.Background(), fetch); !ok || value != 2 {
+		t.Errorf("getOrFetch() after the ttl = %d, %v, want 2, true", value, ok)
+	}
+	if fetches != 2 {
+		t.Errorf("fetched %d times, want 2", fetches)
+	}
+}
+
+func TestValueCacheJitter(t *testing.T) {
+	const ttl, jitter = time.Minute, 30 * time.Second
+	for _, drawn := range []time.Duration{0, 10 * time.Second, jitter - time.Second} {
+		clock := newFakeClock(time.Unix(1700000000, 0))
+		cache := &valueCache[int]{name: "test", ttl: ttl, clock: clock, jitter: func() time.Duration { return drawn }}
+		fetches := 0
+		fetch := func() (int, error) {
+			fetches++
+			return fetches, nil
+		}
+
+		cache.getOrFetch(context.Background(), fetch)
+		clock.Advance(ttl + drawn)
+		if value, _ := cache.getOrFetch(context.Background(), fetch); value != 1 {
+			t.Errorf("jitter %s: value at the end of the effective ttl = %d, want it still cached", drawn, value)
+		}
+		clock.Advance(time.Second)
+		if value, _ := cache.getOrFetch(context.Background(), fetch); value != 2 {
+			t.Errorf("jitter %s: value after the effective ttl = %d, want it refetched", drawn, value)
+		}
+	}
+}
+
+func TestValueCacheMaxStale(t *testing.T) {
+	clock := newFakeClock(time.Unix(1700000000, 0))
+	cache := &valueCache[int]{name: "test", ttl: time.Minute, maxStale: 10 * time.Minute, clock: clock}
+	cache.getOrFetch(context.Background(), func() (int, error) { return 42, nil })
+
+	failing := func() (int, error) { return 0, errors.New("source down") }
+	clock.Advance(5 * time.Minute)
+	if value, ok := cache.getOrFetch(context.Background(), failing); !ok || value != 42 {
+		t.Errorf("getOrFetch() while stale = %d, %v, want 42, true", value, ok)
+	}
+	if err, _ := cache.status(); err == nil {
+		t.Error("status() doesn't report the failed fetch")
+	}
+	clock.Advance(6 * time.Minute)
+	if value, ok := cache.getOrFetch(context.Background(), failing); ok {
+		t.Errorf("getOrFetch() after the max stale age = %d, %v, want it dropped", value, ok)
+	}
+}
+
+func TestCO2Sensors(t *testing.T) {
+	url, failing := newSensorSource(t, fmt.Sprintf(`[
+		{"location": "Hauptraum", "value": 612, "timestamp": %d},
+		{"location": "Werkstatt", "value": 1450, "timestamp": %d},
+		{"location": "Küche", "value": 480}
+	]`, testNow.Add(-time.Minute).Unix(), testNow.Add(-time.Hour).Unix()))
+	config := testConfig(t)
+	config.CO2SensorsURL = url
+	config.CO2MaxAge = 5 * time.Minute
+	config.SensorMaxStale = time.Hour
+	s, clock := newTestServer(t, config, staticState(LabState{}))
+
+	sensors := s.currentSensors(context.Background(), nil)
+	want := []CO2Sensor{
+		{BaseSensor: BaseSensor{Location: "Hauptraum", LastChange: testNow.Add(-time.Minute).Unix()}, Value: 612, Unit: "ppm"},
+		{BaseSensor: BaseSensor{Location: "Küche", LastChange: testNow.Unix()}, Value: 480, Unit: "ppm"},
+	}
+	if sensors == nil || !slices.Equal(sensors.CarbonDioxide, want) {
+		t.Fatalf("co2 = %+v, want %+v", sensors, want)
+	}
+
+	//the cached readings age while the source is down, until all of them are too old
+	failing.Store(true)
+	clock.Advance(10 * time.Minute)
+	if sensors := s.currentSensors(context.Background(), nil); sensors != nil {
+		t.Errorf("co2 = %+v, want the cached readings dropped once they are older than the max age", sensors.CarbonDioxide)
+	}
+}
+
+func TestBeverageSupply(t *testing.T) {
+	url, _ := newSensorSource(t, `[
+		{"location": "Bar", "name": "Club Mate", "value": 42},
+		{"location": "Bar", "name": "Flora Power", "value": 7},
+		{"location": "Keller", "name": "Club Mate", "value": 120, "timestamp": 1700000000}
+	]`)
+	config := testConfig(t)
+	config.BeverageSupplyURL = url
+	s, _ := newTestServer(t, config, staticState(LabState{}))
+
+	sensors := s.currentSensors(context.Background(), nil)
+	want := []BeverageSensor{
+		{BaseSensor: BaseSensor{Location: "Bar", Name: "Club Mate", LastChange: testNow.Unix()}, Value: 42, Unit: "bottle"},
+		{BaseSensor: BaseSensor{Location: "Bar", Name: "Flora Power", LastChange: testNow.Unix()}, Value: 7, Unit: "bottle"},
+		{BaseSensor: BaseSensor{Location: "Keller", Name: "Club Mate", LastChange: 1700000000}, Value: 120, Unit: "bottle"},
+	}
+	if sensors == nil || !slices.Equal(sensors.BeverageSupply, want) {
+		t.Errorf("beverage supply = %+v, want %+v", sensors, want)
+	}
+}
+
+func TestConvertTemperature(t *testing.T) {
+	tests := []struct {
+		value    float64
+		from, to string
+		want     float64
+	}{
+		{71.6, "°F", "°C", 22},
+		{98.6, "°F", "°C", 37},
+		//results are rounded to two decimal places
+		{70, "°F", "°C", 21.11},
+		{295.15, "K", "°C", 22},
+		{295.456, "K", "°C", 22.31},
+		{0, "K", "°C", -273.15},
+		{22, "°C", "°F", 71.6},
+		{22, "°C", "K", 295.15},
+		{21.5, "°C", "°C", 21.5},
+	}
+	for _, test := range tests {
+		got, err := convertTemperature(test.value, test.from, test.to)
+		if err != nil {
+			t.Errorf("convertTemperature(%v, %s, %s) = %v", test.value, test.from, test.to, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("convertTemperature(%v, %s, %s) = %v, want %v", test.value, test.from, test.to, got, test.want)
+		}
+	}
+
+	if _, err := convertTemperature(20, "°R", "°C"); err == nil {
+		t.Error("convertTemperature() from an unknown unit succeeded")
+	}
+}
+
+func TestTemperatureSensorsNormalized(t *testing.T) {
+	url, _ := newSensorSource(t, `[
+		{"location": "Hauptraum", "value": 71.6, "unit": "°F"},
+		{"location": "Serverraum", "value": 300.15, "unit": "K"},
+		{"location": "Küche", "value": 19.5},
+		{"location": "Balkon", "value": 12, "unit": "°R"}
+	]`)
+	config := testConfig(t)
+	config.TemperatureSensorsURL = url
+	s, _ := newTestServer(t, config, staticState(LabState{}))
+
+	sensors := s.currentSensors(context.Background(), nil)
+	want := []TempSensor{
+		{BaseSensor: BaseSensor{Location: "Hauptraum", LastChange: testNow.Unix()}, Value: 22, Unit: "°C"},
+		{BaseSensor: BaseSensor{Location: "Serverraum", LastChange: testNow.Unix()}, Value: 27, Unit: "°C"},
+		{BaseSensor: BaseSensor{Location: "Küche", LastChange: testNow.Unix()}, Value: 19.5, Unit: "°C"},
+	}
+	if sensors == nil || !slices.Equal(sensors.Temperature, want) {
+		t.Errorf("temperature = %+v, want %+v with the unknown unit dropped", sensors, want)
+	}
+}
+
+func TestHumiditySensors(t *testing.T) {
+	url, _ := newSensorSource(t, `[
+		{"location": "Hauptraum", "value": 45.5},
+		{"location": "Keller", "value": 104},
+		{"location": "Balkon", "value": -3}
+	]`)
+	config := testConfig(t)
+	config.HumiditySensorsURL = url
+	s, _ := newTestServer(t, config, staticState(LabState{}))
+
+	sensors := s.currentSensors(context.Background(), nil)
+	//readings outside of 0-100% are clamped
+	want := []HumiditySensor{
+		{BaseSensor: BaseSensor{Location: "Hauptraum", LastChange: testNow.Unix()}, Value: 45.5, Unit: "%"},
+		{BaseSensor: BaseSensor{Location: "Keller", LastChange: testNow.Unix()}, Value: 100, Unit: "%"},
+		{BaseSensor: BaseSensor{Location: "Balkon", LastChange: testNow.Unix()}, Value: 0, Unit: "%"},
+	}
+	if sensors == nil || !slices.Equal(sensors.Humidity, want) {
+		t.Errorf("humidity = %+v, want %+v", sensors, want)
+	}
+}
+
+func TestConvertPressureToHectopascal(t *testing.T) {
+	tests := []struct {
+		value float64
+		unit  string
+		want  float64
+	}{
+		{1013.25, "hPa", 1013.25},
+		{1013.25, "mbar", 1013.25},
+		{101325, "Pa", 1013.25},
+		//pascal readings are rounded to two decimal places of hPa
+		{101325.4, "Pa", 1013.25},
+		{98765.6, "Pa", 987.66},
+	}
+	for _, test := range tests {
+		got, err := convertPressureToHectopascal(test.value, test.unit)
+		if err != nil {
+			t.Errorf("convertPressureToHectopascal(%v, %s) = %v", test.value, test.unit, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("convertPressureToHectopascal(%v, %s) = %v, want %v", test.value, test.unit, got, test.want)
+		}
+	}
+
+	if _, err := convertPressureToHectopascal(760, "mmHg"); err == nil {
+		t.Error("convertPressureToHectopascal() from an unknown unit succeeded")
+	}
+}
+
+func TestSortRadiationReadings(t *testing.T) {
+	s, _ := newTestServer(t, testConfig(t), staticState(LabState{}))
+	readings := []RadiationReading{
+		{SensorReading: SensorReading{Location: "Dach", Value: 18}, Type: "gamma", DeadTime: 0.00019, ConversionFactor: 0.0057},
+		{SensorReading: SensorReading{Location: "Labor", Value: 0.2, Unit: "µSv/h"}, Type: "beta_gamma"},
+		{SensorReading: SensorReading{Location: "Keller", Value: 3}, Type: "alpha"},
+		{SensorReading: SensorReading{Location: "Labor", Value: 5}, Type: "beta"},
+	}
+
+	radiation := s.sortRadiationReadings(readings, []string{"gamma", "beta_gamma"}, testNow)
+	base := func(location string) BaseSensor {
+		return BaseSensor{Location: location, LastChange: testNow.Unix()}
+	}
+	wantGamma := []RadiationSensor{{BaseSensor: base("Dach"), Value: 18, Unit: "cpm", DeadTime: 0.00019, ConversionFactor: 0.0057}}
+	wantBetaGamma := []RadiationSensor{{BaseSensor: base("Labor"), Value: 0.2, Unit: "µSv/h"}}
+	if !slices.Equal(radiation.Gamma, wantGamma) || !slices.Equal(radiation.BetaGamma, wantBetaGamma) {
+		t.Errorf("gamma = %+v, beta_gamma = %+v, want %+v, %+v", radiation.Gamma, radiation.BetaGamma, wantGamma, wantBetaGamma)
+	}
+	//the types the source isn't configured to provide are dropped
+	if len(radiation.Alpha) > 0 || len(radiation.Beta) > 0 {
+		t.Errorf("alpha = %+v, beta = %+v, want none", radiation.Alpha, radiation.Beta)
+	}
+
+	//a source of a single type may leave the type out
+	untyped := []RadiationReading{{SensorReading: SensorReading{Location: "Dach", Value: 21}}}
+	radiation = s.sortRadiationReadings(untyped, []string{"beta"}, testNow)
+	if want := []RadiationSensor{{BaseSensor: base("Dach"), Value: 21, Unit: "cpm"}}; !slices.Equal(radiation.Beta, want) {
+		t.Errorf("beta = %+v, want %+v", radiation.Beta, want)
+	}
+}
+
+func TestSensorLastChange(t *testing.T) {
+	const measured = 1709315000 //a few minutes before testNow
+	readings := fmt.Sprintf(`[
+		{"location": "Hauptraum", "value": 20, "timestamp": %d},
+		{"location": "Keller", "value": 10}
+	]`, measured)
+	config := testConfig(t)
+	config.TemperatureSensorsURL, _ = newSensorSource(t, readings)
+	config.HumiditySensorsURL, _ = newSensorSource(t, readings)
+	config.BarometerSensorsURL, _ = newSensorSource(t, readings)
+	config.BeverageSupplyURL, _ = newSensorSource(t, readings)
+	config.RadiationSensorsURL, _ = newSensorSource(t, readings)
+	config.RadiationTypes = []string{"gamma"}
+	config.DoorLockURL, _ = newSensorSource(t, fmt.Sprintf(`{"locked": true, "timestamp": %d}`, measured))
+	s, clock := newTestServer(t, config, staticState(LabState{}))
+	//readings are fetched a while after the server started
+	clock.Advance(time.Minute)
+	fetchedAt := testNow.Add(time.Minute).Unix()
+
+	sensors := s.currentSensors(context.Background(), nil)
+	if sensors == nil || sensors.Radiation == nil || len(sensors.DoorLocked) != 1 {
+		t.Fatalf("sensors = %+v, want readings of every source", sensors)
+	}
+	bases := map[string][]BaseSensor{}
+	for _, sensor := range sensors.Temperature {
+		bases["temperature"] = append(bases["temperature"], sensor.BaseSensor)
+	}
+	for _, sensor := range sensors.Humidity {
+		bases["humidity"] = append(bases["humidity"], sensor.BaseSensor)
+	}
+	for _, sensor := range sensors.Barometer {
+		bases["barometer"] = append(bases["barometer"], sensor.BaseSensor)
+	}
+	for _, sensor := range sensors.BeverageSupply {
+		bases["beverage_supply"] = append(bases["beverage_supply"], sensor.BaseSensor)
+	}
+	for _, sensor := range sensors.Radiation.Gamma {
+		bases["radiation"] = append(bases["radiation"], sensor.BaseSensor)
+	}
+	for kind, got := range bases {
+		if len(got) != 2 || got[0].LastChange != measured || got[1].LastChange != fetchedAt {
+			t.Errorf("%s = %+v, want lastchange %d as reported and %d as fetched", kind, got, measured, fetchedAt)
+		}
+	}
+	if len(bases) != 5 {
+		t.Errorf("got readings of %d sources, want 5", len(bases))
+	}
+	if door := sensors.DoorLocked[0]; door.LastChange != measured {
+		t.Errorf("door_locked lastchange = %d, want %d", door.LastChange, measured)
+	}
+}
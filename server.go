@@ -0,0 +1,359 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/sync/singleflight"
+)
+
+// Server serves the SpaceAPI document and holds everything it is assembled from
+type Server struct {
+	config       Config
+	doc          *SpaceAPIv15
+	mux          *http.ServeMux
+	client       *http.Client
+	logger       *slog.Logger
+	clock        Clock
+	random       func(n time.Duration) time.Duration
+	stateFetcher StateFetcher
+	location     *time.Location
+
+	stateCache    *stateCache
+	stateFetches  singleflight.Group
+	streams       sync.WaitGroup  // open websockets
+	workers       sync.WaitGroup  // background workers started by Run
+	workersCtx    context.Context // cancelled when Run shuts down the background workers
+	workersMu     sync.Mutex      // guards starting workers against Run waiting for them
+	stopping      bool            // set once Run waits for the workers, no new ones are started
+	stateOverride *stateOverride
+	stateHistory  *stateHistory
+	stateChanges  *stateBroadcaster
+	mqttSensors   *mqttSensorStore
+
+	temperatureCache    *valueCache[[]TempSensor]
+	co2Cache            *valueCache[[]CO2Sensor]
+	humidityCache       *valueCache[[]HumiditySensor]
+	barometerCache      *valueCache[[]BarometerSensor]
+	radiationCache      *valueCache[*RadiationSensors]
+	doorLockCache       *valueCache[[]DoorSensor]
+	beverageSupplyCache *valueCache[[]BeverageSensor]
+	keymastersCache     *valueCache[[]Keymaster]
+	eventsCache         *valueCache[[]Event]
+	mastodonCache       *valueCache[[]Event]
+	mastodonThrottle    *throttle
+	gitHubCache         *valueCache[[]string]
+	gitHubThrottle      *throttle
+
+	hasConfig bool
+}
+
+// Option configures a Server created by NewServer
+type Option func(*Server)
+
+// WithConfig sets the configuration, by default it is read from the environment
+func WithConfig(config Config) Option {
+	return func(s *Server) {
+		s.config = config
+		s.hasConfig = true
+	}
+}
+
+// WithDocument sets the static space document, by default it is loaded from the configured file
+// or the built-in Metalab document is used
+func WithDocument(doc *SpaceAPIv15) Option {
+	return func(s *Server) {
+		s.doc = doc
+	}
+}
+
+// WithStateFetcher sets the source of the lab state, by default the configured state apis
+func WithStateFetcher(fetcher StateFetcher) Option {
+	return func(s *Server) {
+		s.stateFetcher = fetcher
+	}
+}
+
+// WithHTTPClient sets the client used for all upstream requests, by default one with a pool of keep-alive connections
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Server) {
+		s.client = client
+	}
+}
+
+// WithClock sets the clock everything time dependent is based on, by default the wall clock
+func WithClock(clock Clock) Option {
+	return func(s *Server) {
+		s.clock = clock
+	}
+}
+
+// WithRandom sets the source of random durations in [0, n), e.g. for the cache ttl jitter, by default math/rand
+func WithRandom(random func(n time.Duration) time.Duration) Option {
+	return func(s *Server) {
+		s.random = random
+	}
+}
+
+// WithLogger sets the logger, by default slog.Default()
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Server) {
+		s.logger = logger
+	}
+}
+
+// NewServer creates a server, prepares the space document and restores the persisted state
+func NewServer(opts ...Option) (*Server, error) {
+	s := &Server{
+		logger:     slog.Default(),
+		clock:      realClock{},
+		random:     rand.N[time.Duration],
+		workersCtx: context.Background(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if !s.hasConfig {
+		config, err := LoadConfig()
+		if err != nil {
+			return nil, fmt.Errorf("invalid configuration: %w", err)
+		}
+		s.config = config
+	}
+	if s.client == nil {
+		s.client = newHTTPClient(s.config)
+	}
+	if s.stateFetcher == nil && s.config.StateSourceFile != "" {
+		s.stateFetcher = NewFileStateFetcher(s.config.StateSourceFile, s.config, s.logger)
+	}
+	if s.stateFetcher == nil {
+		s.stateFetcher = NewHTTPStateFetcher(s.config, s.client, s.clock, s.logger)
+	}
+
+	if s.doc == nil {
+		s.doc = defaultSpaceDocument()
+		if s.config.ConfigFile != "" {
+			doc, err := loadSpaceDocument(s.config.ConfigFile)
+			if errors.Is(err, os.ErrNotExist) {
+				s.logger.Warn("space document not found, using defaults", "path", s.config.ConfigFile)
+			} else if err != nil {
+				return nil, fmt.Errorf("invalid space document %s: %w", s.config.ConfigFile, err)
+			} else {
+				s.doc = doc
+			}
+		}
+	}
+
+	ttl := s.config.StateCacheTTL
+	s.stateCache = newStateCache(ttl, s.clock)
+	s.stateChanges = newStateBroadcaster(s.logger)
+	s.stateOverride = &stateOverride{clock: s.clock}
+	s.stateHistory = newStateHistory(s.config.HistorySize)
+	s.mqttSensors = &mqttSensorStore{values: make(map[string]mqttSensorValue), clock: s.clock}
+	s.temperatureCache = &valueCache[[]TempSensor]{name: "temperature", ttl: ttl, maxStale: s.config.SensorMaxStale, clock: s.clock, jitter: s.jitter}
+	s.co2Cache = &valueCache[[]CO2Sensor]{name: "co2", ttl: ttl, maxStale: s.config.SensorMaxStale, clock: s.clock, jitter: s.jitter}
+	s.humidityCache = &valueCache[[]HumiditySensor]{name: "humidity", ttl: ttl, maxStale: s.config.SensorMaxStale, clock: s.clock, jitter: s.jitter}
+	s.barometerCache = &valueCache[[]BarometerSensor]{name: "barometer", ttl: ttl, maxStale: s.config.SensorMaxStale, clock: s.clock, jitter: s.jitter}
+	s.radiationCache = &valueCache[*RadiationSensors]{name: "radiation", ttl: ttl, maxStale: s.config.SensorMaxStale, clock: s.clock, jitter: s.jitter}
+	s.doorLockCache = &valueCache[[]DoorSensor]{name: "door_lock", ttl: ttl, maxStale: s.config.SensorMaxStale, clock: s.clock, jitter: s.jitter}
+	s.beverageSupplyCache = &valueCache[[]BeverageSensor]{name: "beverage_supply", ttl: ttl, maxStale: s.config.SensorMaxStale, clock: s.clock, jitter: s.jitter}
+	s.keymastersCache = &valueCache[[]Keymaster]{name: "keymasters", ttl: s.config.KeymastersRefresh, clock: s.clock, jitter: s.jitter}
+	s.eventsCache = &valueCache[[]Event]{name: "events", ttl: 15 * time.Minute, clock: s.clock, jitter: s.jitter}
+	s.mastodonCache = &valueCache[[]Event]{name: "mastodon", ttl: s.config.MastodonRefresh, clock: s.clock, jitter: s.jitter}
+	s.mastodonThrottle = newThrottle()
+	s.gitHubCache = &valueCache[[]string]{name: "github", ttl: s.config.GitHubRefresh, clock: s.clock, jitter: s.jitter}
+	s.gitHubThrottle = newThrottle()
+
+	if s.config.StateFile != "" {
+		s.restorePersistedState(s.config.StateFile)
+	}
+	if s.config.HistoryFile != "" {
+		s.restoreHistory(s.config.HistoryFile)
+	}
+
+	if err := s.validateSources(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	if err := s.prepareDocument(); err != nil {
+		return nil, err
+	}
+
+	s.mux = http.NewServeMux()
+	s.routes()
+	return s, nil
+}
+
+// prepareDocument fills the configured parts into the static document and validates it against the schema
+func (s *Server) prepareDocument() error {
+	//everything time of day related breaks subtly with a wrong time zone, so refuse to start instead
+	location, err := loadTimezone(s.doc)
+	if err != nil {
+		return fmt.Errorf("invalid space document: %w", err)
+	}
+	s.location = location
+	s.doc.Cache = &Cache{Schedule: cacheSchedule(s.config.StateCacheTTL)}
+	if s.doc.State.Icon == nil && s.config.StateIconOpen != "" {
+		s.doc.State.Icon = &StateIcon{Open: s.config.StateIconOpen, Closed: s.config.StateIconClosed}
+	}
+	if cams := s.validURLs("cam", s.config.CamURLs); len(cams) > 0 {
+		s.doc.Cam = cams
+	}
+	s.doc.Feeds = s.mergeFeeds(s.doc.Feeds, s.config.Feeds)
+	if len(s.config.Extensions) > 0 {
+		s.doc.Extensions = s.config.Extensions
+	}
+
+	violations, err := validateStaticDocument(s.doc)
+	if err != nil {
+		return fmt.Errorf("error while validating space document: %w", err)
+	}
+	for _, violation := range violations {
+		s.logger.Warn("space document violates the SpaceAPI schema", "violation", violation)
+	}
+	if len(violations) > 0 && s.config.StrictValidation {
+		return fmt.Errorf("space document is invalid, %d schema violations", len(violations))
+	}
+	return nil
+}
+
+func (s *Server) routes() {
+	s.mux.HandleFunc("/", readOnly(s.handleIndex))
+	s.mux.HandleFunc("/v13", readOnly(s.handleSpaceApiV13))
+	s.mux.HandleFunc("/v14", readOnly(s.handleSpaceApiV15)) //v14 is also compatible with v15
+	s.mux.HandleFunc("/v15", readOnly(s.handleSpaceApiV15))
+	s.mux.HandleFunc("/v15/validate", readOnly(s.handleValidate))
+	s.mux.HandleFunc("/healthz", readOnly(handleHealthz))
+	s.mux.HandleFunc("/readyz", readOnly(s.handleReadyz))
+	s.mux.HandleFunc("/admin/state", s.requireAdmin(s.handleAdminState))
+	s.mux.HandleFunc("/admin/refresh", s.requireAdmin(s.handleAdminRefresh))
+	s.mux.HandleFunc("/metrics", readOnly(promhttp.Handler().ServeHTTP))
+	s.mux.HandleFunc("/events/state", readOnly(s.handleStateEvents))
+	s.mux.HandleFunc("/ws/state", readOnly(s.handleStateWebSocket))
+	s.mux.HandleFunc("/history/state", readOnly(s.handleStateHistory))
+	s.mux.HandleFunc("/sensors", readOnly(s.handleSensors))
+	s.mux.HandleFunc("/state", readOnly(s.handleState))
+	s.mux.HandleFunc("/about", readOnly(s.handleAbout))
+	s.mux.HandleFunc("/badge.svg", readOnly(s.handleBadge))
+	s.mux.HandleFunc("/version", readOnly(s.handleVersion))
+	s.mux.HandleFunc("/opening.jsonld", readOnly(s.handleOpeningJSONLD))
+	s.mux.HandleFunc("/radioshow.ics", readOnly(s.handleRadioShowICal))
+	s.mux.HandleFunc("/debug/sources", s.requireAdmin(readOnly(s.handleDebugSources)))
+	s.mux.HandleFunc("/debug/config", s.requireAdmin(readOnly(s.handleDebugConfig)))
+}
+
+// Handler returns the routes of the server wrapped in its middleware
+func (s *Server) Handler() http.Handler {
+	var handler http.Handler = s.cors(s.rateLimit(compress(countRequests(s.recoverPanics(s.timeout(s.mux))))))
+	if s.config.LogRequests {
+		handler = s.logRequests(handler)
+	}
+	return handler
+}
+
+// Run starts the background workers and serves http until ctx is cancelled, then shuts down gracefully
+func (s *Server) Run(ctx context.Context) error {
+	workersCtx, stopWorkers := context.WithCancel(ctx)
+	defer stopWorkers()
+	//workers started later on, e.g. for webhooks, stop with the others
+	s.workersCtx = workersCtx
+	s.startWorker(func() { s.runStatePoller(workersCtx, s.config.StateRefreshInterval) })
+	if watcher, ok := s.stateFetcher.(stateWatcher); ok && s.config.StateSourceFileWatch {
+		s.startWorker(func() { s.runStateWatcher(workersCtx, watcher) })
+	}
+	if s.config.DirectoryRegister {
+		s.startWorker(func() { s.runDirectoryHeartbeat(workersCtx, s.config.DirectoryInterval) })
+	}
+	if s.config.MQTTBroker != "" {
+		client := s.newMQTTClient()
+		s.startWorker(func() { s.runMQTTPublisher(workersCtx, client) })
+	}
+
+	listener, err := listen(s.config.ListenAddr, s.config.ListenSocketMode)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Handler: s.Handler()}
+	serveErr := make(chan error, 1)
+	go func() {
+		var err error
+		if s.config.TLSCertFile != "" {
+			s.logger.Info("server starting", "addr", s.config.ListenAddr, "mode", "https")
+			err = server.ServeTLS(listener, s.config.TLSCertFile, s.config.TLSKeyFile)
+		} else {
+			s.logger.Info("server starting", "addr", s.config.ListenAddr, "mode", "http")
+			err = server.Serve(listener)
+		}
+		serveErr <- err
+	}()
+
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	case <-ctx.Done():
+	}
+	s.logger.Info("shutting down")
+	stopWorkers()
+	s.workersMu.Lock()
+	s.stopping = true
+	s.workersMu.Unlock()
+
+	//give in-flight requests (which may be waiting on the state api) a chance to finish,
+	//streaming clients are told that the server is closing and disconnected
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.config.ShutdownTimeout)
+	defer cancel()
+	s.stateChanges.close()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("error while shutting down: %w", err)
+	}
+	//websockets are hijacked connections, which Shutdown doesn't wait for
+	if err := waitFor(shutdownCtx, &s.streams); err != nil {
+		return fmt.Errorf("error while closing websockets: %w", err)
+	}
+	if err := waitFor(shutdownCtx, &s.workers); err != nil {
+		return fmt.Errorf("error while stopping background workers: %w", err)
+	}
+	return nil
+}
+
+// startWorker runs a background worker that Run waits for on shutdown, it returns false without running the worker
+// once Run is shutting down
+func (s *Server) startWorker(worker func()) bool {
+	s.workersMu.Lock()
+	defer s.workersMu.Unlock()
+
+	if s.stopping {
+		return false
+	}
+	s.workers.Add(1)
+	go func() {
+		defer s.workers.Done()
+		worker()
+	}()
+	return true
+}
+
+// waitFor waits until wg is done or ctx is cancelled
+func waitFor(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
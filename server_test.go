@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+// testNow is the time the clock of test servers starts at
+var testNow = time.Date(2024, 3, 1, 18, 0, 0, 0, time.UTC)
+
+// testConfig returns the configuration of a server started without any environment variables
+func testConfig(t *testing.T) Config {
+	t.Helper()
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("default configuration: %v", err)
+	}
+	return config
+}
+
+// staticState returns a StateFetcher that always reports state
+func staticState(state LabState) StateFetchFunc {
+	return func(ctx context.Context) (LabState, error) {
+		return state, nil
+	}
+}
+
+// newTestServer creates a server with config that gets the lab state from fetcher and tells time by the returned clock
+func newTestServer(t *testing.T, config Config, fetcher StateFetcher, opts ...Option) (*Server, *fakeClock) {
+	t.Helper()
+	clock := newFakeClock(testNow)
+	opts = append([]Option{
+		WithConfig(config),
+		WithStateFetcher(fetcher),
+		WithClock(clock),
+		WithRandom(func(time.Duration) time.Duration { return 0 }),
+		WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+	}, opts...)
+	s, err := NewServer(opts...)
+	if err != nil {
+		t.Fatalf("NewServer() = %v", err)
+	}
+	return s, clock
+}
+
+// serve sends a request through the handler of the server, including all middleware
+func serve(s *Server, method, target string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	for key, values := range header {
+		r.Header[key] = values
+	}
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, r)
+	return w
+}
+
+// decodeJSON decodes the body of a response, failing the test if it isn't valid JSON
+func decodeJSON[T any](t *testing.T, w *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+		t.Fatalf("invalid JSON response %q: %v", w.Body.String(), err)
+	}
+	return v
+}
+
+func TestSpaceAPIv15State(t *testing.T) {
+	tests := []struct {
+		name    string
+		fetcher StateFetcher
+		open    *bool
+	}{
+		{"open", staticState(LabState{Open: Pointer(true), LastChange: Pointer[int64](1700000000)}), Pointer(true)},
+		{"closed", staticState(LabState{Open: Pointer(false), LastChange: Pointer[int64](1700000000)}), Pointer(false)},
+		{"unknown", staticState(LabState{}), nil},
+		{"fetch error", StateFetchFunc(func(ctx context.Context) (LabState, error) {
+			return LabState{}, errors.New("state api unreachable")
+		}), nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, _ := newTestServer(t, testConfig(t), test.fetcher)
+			w := serve(s, "GET", "/v15", nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+			}
+			if contentType := w.Header().Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", contentType)
+			}
+
+			doc := decodeJSON[SpaceAPIv15](t, w)
+			if doc.Space != "Metalab" || len(doc.APICompatibility) == 0 {
+				t.Errorf("space = %q, api_compatibility = %v, want the static document", doc.Space, doc.APICompatibility)
+			}
+			if doc.State == nil {
+				t.Fatal("state is missing")
+			}
+			if !equalState(doc.State.Open, test.open) {
+				t.Errorf("state.open = %s, want %s", formatState(doc.State.Open), formatState(test.open))
+			}
+		})
+	}
+}
+
+func TestSpaceAPIv13(t *testing.T) {
+	s, _ := newTestServer(t, testConfig(t), staticState(LabState{Open: Pointer(true), LastChange: Pointer[int64](1700000000)}))
+	w := serve(s, "GET", "/v13", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	doc := decodeJSON[SpaceAPIv13](t, w)
+	if doc.API != "0.13" {
+		t.Errorf("api = %q, want 0.13", doc.API)
+	}
+	if doc.State == nil || doc.State.Open == nil || !*doc.State.Open || doc.State.LastChange != 1700000000 {
+		t.Errorf("state = %+v, want open since 1700000000", doc.State)
+	}
+}
+
+func TestSpaceAPIHead(t *testing.T) {
+	s, _ := newTestServer(t, testConfig(t), staticState(LabState{Open: Pointer(true), LastChange: Pointer[int64](1700000000)}))
+	//the body of a HEAD response is dropped by the http server, so the headers are checked against a real one
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+
+	//the client only asks for gzip on GET, which would change the headers
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	for _, path := range []string{"/v14", "/v15"} {
+		get, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		get.Body.Close()
+		head, err := client.Head(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(head.Body)
+		head.Body.Close()
+		if head.StatusCode != http.StatusOK {
+			t.Fatalf("HEAD %s: status = %d, want %d", path, head.StatusCode, http.StatusOK)
+		}
+		if len(body) != 0 {
+			t.Errorf("HEAD %s: body = %q, want none", path, body)
+		}
+		for _, key := range []string{"Content-Type", "ETag", "Last-Modified", "Content-Length"} {
+			if head.Header.Get(key) == "" || head.Header.Get(key) != get.Header.Get(key) {
+				t.Errorf("HEAD %s: %s = %q, want %q as for GET", path, key, head.Header.Get(key), get.Header.Get(key))
+			}
+		}
+	}
+}
+
+func TestSpaceAPINotModified(t *testing.T) {
+	s, _ := newTestServer(t, testConfig(t), staticState(LabState{Open: Pointer(true)}))
+	etag := serve(s, "GET", "/v15", nil).Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("no ETag header")
+	}
+
+	w := serve(s, "GET", "/v15", http.Header{"If-None-Match": {etag}})
+	if w.Code != http.StatusNotModified {
+		t.Errorf("status with matching If-None-Match = %d, want %d", w.Code, http.StatusNotModified)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("body = %q, want none", w.Body.String())
+	}
+	w = serve(s, "GET", "/v15", http.Header{"If-None-Match": {`"outdated"`}})
+	if w.Code != http.StatusOK {
+		t.Errorf("status with other If-None-Match = %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	s, _ := newTestServer(t, testConfig(t), staticState(LabState{Open: Pointer(true)}))
+	for _, target := range []string{"/v13", "/v14", "/v15", "/state"} {
+		for _, method := range []string{"POST", "PUT", "DELETE"} {
+			w := serve(s, method, target, nil)
+			if w.Code != http.StatusMethodNotAllowed {
+				t.Errorf("%s %s: status = %d, want %d", method, target, w.Code, http.StatusMethodNotAllowed)
+			}
+			if allow := w.Header().Get("Allow"); allow != "GET, HEAD" {
+				t.Errorf("%s %s: Allow = %q, want GET, HEAD", method, target, allow)
+			}
+		}
+	}
+}
+
+func TestSpaceAPIFields(t *testing.T) {
+	s, _ := newTestServer(t, testConfig(t), staticState(LabState{Open: Pointer(true)}))
+	doc := decodeJSON[map[string]json.RawMessage](t, serve(s, "GET", "/v15?fields=state,unknown", nil))
+	for _, field := range []string{"api_compatibility", "space", "state"} {
+		if _, ok := doc[field]; !ok {
+			t.Errorf("field %s is missing", field)
+		}
+	}
+	if len(doc) != 3 {
+		t.Errorf("fields = %v, want only api_compatibility, space and state", slices.Sorted(maps.Keys(doc)))
+	}
+
+	all := decodeJSON[map[string]json.RawMessage](t, serve(s, "GET", "/v15", nil))
+	for _, field := range []string{"logo", "url", "location", "contact"} {
+		if _, ok := all[field]; !ok {
+			t.Errorf("field %s is missing without ?fields", field)
+		}
+	}
+}
+
+func TestStateEndpoint(t *testing.T) {
+	s, clock := newTestServer(t, testConfig(t), staticState(LabState{Open: Pointer(false), LastChange: Pointer[int64](1700000000)}))
+	w := serve(s, "GET", "/state", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	event := decodeJSON[StateEvent](t, w)
+	if event.Open == nil || *event.Open || event.LastChange != 1700000000 {
+		t.Errorf("state = %+v, want closed since 1700000000", event)
+	}
+	if modified := w.Header().Get("Last-Modified"); modified != "Tue, 14 Nov 2023 22:13:20 GMT" {
+		t.Errorf("Last-Modified = %q, want the last change", modified)
+	}
+
+	//the state stays cached within the ttl, even though the clock moved on
+	clock.Advance(time.Second)
+	w = serve(s, "GET", "/state", http.Header{"If-Modified-Since": {"Tue, 14 Nov 2023 22:13:20 GMT"}})
+	if w.Code != http.StatusNotModified {
+		t.Errorf("status with If-Modified-Since = %d, want %d", w.Code, http.StatusNotModified)
+	}
+}
+
+func TestAboutEndpoint(t *testing.T) {
+	s, _ := newTestServer(t, testConfig(t), staticState(LabState{Open: Pointer(true), Message: "open for everyone"}))
+	about := decodeJSON[About](t, serve(s, "GET", "/about", nil))
+	if about.Space != "Metalab" || about.Open == nil || !*about.Open || about.Message != "open for everyone" {
+		t.Errorf("about = %+v, want Metalab open with the fetched message", about)
+	}
+}
+
+func TestStartWorkerWhileStopping(t *testing.T) {
+	s, _ := newTestServer(t, testConfig(t), staticState(LabState{}))
+	done := make(chan struct{})
+	if !s.startWorker(func() { close(done) }) {
+		t.Fatal("startWorker() = false before shutting down, want the worker started")
+	}
+	<-done
+
+	s.workersMu.Lock()
+	s.stopping = true
+	s.workersMu.Unlock()
+	if s.startWorker(func() { t.Error("worker started while stopping") }) {
+		t.Error("startWorker() = true while stopping, want false")
+	}
+	s.workers.Wait()
+}
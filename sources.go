@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SourceStatus describes the health of a single upstream data source
+type SourceStatus struct {
+	Name        string `json:"name"`
+	URL         string `json:"url"`
+	LastSuccess int64  `json:"last_success,omitempty"`
+	LastError   string `json:"last_error,omitempty"`
+}
+
+// dataSource is an upstream the document is assembled from
+type dataSource struct {
+	name   string
+	urls   []string
+	status func() (lastErr error, lastSuccess time.Time)
+}
+
+// dataSources lists the upstreams of the server, including the ones that are not configured
+func (s *Server) dataSources() []dataSource {
+	stateSources := s.config.StateAPIURLs
+	if s.config.StateSourceFile != "" {
+		stateSources = []string{s.config.StateSourceFile}
+	}
+	return []dataSource{
+		{"state", stateSources, s.stateCache.status},
+		{"temperature", nonEmpty(s.config.TemperatureSensorsURL), s.temperatureCache.status},
+		{"co2", nonEmpty(s.config.CO2SensorsURL), s.co2Cache.status},
+		{"humidity", nonEmpty(s.config.HumiditySensorsURL), s.humidityCache.status},
+		{"barometer", nonEmpty(s.config.BarometerSensorsURL), s.barometerCache.status},
+		{"radiation", nonEmpty(s.config.RadiationSensorsURL), s.radiationCache.status},
+		{"door_lock", nonEmpty(s.config.DoorLockURL), s.doorLockCache.status},
+		{"beverage_supply", nonEmpty(s.config.BeverageSupplyURL), s.beverageSupplyCache.status},
+		{"keymasters", nonEmpty(s.config.KeymastersURL), s.keymastersCache.status},
+		{"events", nonEmpty(s.config.EventsCalendarURL), s.eventsCache.status},
+		{"mastodon", nonEmpty(s.config.MastodonAccount), s.mastodonCache.status},
+		{"github", nonEmpty(s.config.GitHubOrg), s.gitHubCache.status},
+	}
+}
+
+// nonEmpty returns the value as a list, which is empty for an unconfigured source
+func nonEmpty(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return []string{value}
+}
+
+// validateSources checks that every configured upstream url is an absolute url
+func (s *Server) validateSources() error {
+	var errs []error
+	for _, u := range s.config.StateAPIURLs {
+		if err := validateURL(u); err != nil {
+			errs = append(errs, fmt.Errorf("invalid STATE_API_URLS entry %q: %w", u, err))
+		}
+	}
+	for _, source := range []struct{ key, url string }{
+		{"TEMPERATURE_SENSORS_URL", s.config.TemperatureSensorsURL},
+		{"CO2_SENSORS_URL", s.config.CO2SensorsURL},
+		{"HUMIDITY_SENSORS_URL", s.config.HumiditySensorsURL},
+		{"BAROMETER_SENSORS_URL", s.config.BarometerSensorsURL},
+		{"RADIATION_SENSORS_URL", s.config.RadiationSensorsURL},
+		{"DOOR_LOCK_URL", s.config.DoorLockURL},
+		{"BEVERAGE_SUPPLY_URL", s.config.BeverageSupplyURL},
+		{"KEYMASTERS_URL", s.config.KeymastersURL},
+		{"EVENTS_CALENDAR_URL", s.config.EventsCalendarURL},
+		{"GITHUB_API_URL", s.config.GitHubAPIURL},
+		{"DIRECTORY_URL", s.config.DirectoryURL},
+		{"PUBLIC_URL", s.config.PublicURL},
+	} {
+		if source.url == "" {
+			continue
+		}
+		if err := validateURL(source.url); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s %q: %w", source.key, source.url, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// handleDebugSources reports the last success and the last error of every configured data source, passwords
+// in the source urls are redacted, including the ones quoted in the errors
+func (s *Server) handleDebugSources(w http.ResponseWriter, r *http.Request) {
+	statuses := []SourceStatus{}
+	for _, source := range s.dataSources() {
+		if len(source.urls) == 0 {
+			continue
+		}
+		urls := make([]string, len(source.urls))
+		for i, u := range source.urls {
+			urls[i] = redactURL(u)
+		}
+		status := SourceStatus{Name: source.name, URL: strings.Join(urls, ", ")}
+		lastErr, lastSuccess := source.status()
+		if lastErr != nil {
+			status.LastError = lastErr.Error()
+			for i, u := range source.urls {
+				status.LastError = strings.ReplaceAll(status.LastError, u, urls[i])
+			}
+		}
+		if !lastSuccess.IsZero() {
+			status.LastSuccess = lastSuccess.Unix()
+		}
+		statuses = append(statuses, status)
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	s.writeJSON(w, r, statuses)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+)
+
+// currentState returns a per-request copy of the state of the document with the cached lab state filled in,
+// if the last refresh failed the response is marked as stale
+func (s *Server) currentState(w http.ResponseWriter, r *http.Request) *State {
+	//the background poller keeps the cache up to date, requests only wait for the state fetcher while nothing
+	//was fetched yet, e.g. right after startup or when the server is used without the poller
+	labState, hasState, _ := s.stateCache.lookup()
+	lastErr, _ := s.stateCache.status()
+	if !hasState && lastErr == nil {
+		state, err := s.refreshLabState(r.Context())
+		labState, hasState, lastErr = state, err == nil, err
+	}
+	if lastErr != nil && hasState {
+		s.logger.Debug("lab state unavailable, serving stale state", "error", lastErr)
+		w.Header().Set("X-State-Stale", "true")
+	} else if !hasState {
+		s.logger.Debug("no lab state known yet")
+	}
+
+	//the shared state must not be mutated by concurrent requests
+	state := *s.doc.State
+	state.Open = labState.Open
+	state.LastChange = 0
+	if labState.LastChange != nil {
+		state.LastChange = *labState.LastChange
+	}
+	state.Message = labState.Message
+	//who opened or closed the space is only published if explicitly allowed
+	state.TriggerPerson = ""
+	if s.config.ExposeTriggerPerson {
+		state.TriggerPerson = labState.TriggerPerson
+	}
+	//a manual override takes precedence over the fetched state
+	if open, message, since, ok := s.stateOverride.get(); ok {
+		state.Open = Pointer(open)
+		state.Message = message
+		state.TriggerPerson = ""
+		state.LastChange = since.Unix()
+	}
+	//without a specific message the configured one is used, in the language the client prefers
+	if state.Message == "" && state.Open != nil {
+		variants := s.config.StateMessagesClosed
+		if *state.Open {
+			variants = s.config.StateMessagesOpen
+		}
+		if len(variants) > 0 {
+			w.Header().Add("Vary", "Accept-Language")
+			state.Message = localizedMessage(variants, r.Header.Get("Accept-Language"), s.config.DefaultLanguage)
+		}
+	}
+	return &state
+}
+
+// handleState serves just the open state and the time of its last change, for widgets which don't need the whole document
+func (s *Server) handleState(w http.ResponseWriter, r *http.Request) {
+	s.setCacheControl(w)
+	state := s.currentState(w, r)
+	if checkNotModifiedSince(w, r, state.LastChange) {
+		return
+	}
+	//same shape as the streamed state events
+	s.writeJSON(w, r, StateEvent{Open: state.Open, LastChange: state.LastChange})
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCurrentStateFirstFetchFails(t *testing.T) {
+	s, _ := newTestServer(t, testConfig(t), StateFetchFunc(func(ctx context.Context) (LabState, error) {
+		return LabState{}, errors.New("state api unreachable")
+	}))
+	w := serve(s, "GET", "/v15", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if doc := decodeJSON[SpaceAPIv15](t, w); doc.State.Open != nil {
+		t.Errorf("state.open = %s, want unknown", formatState(doc.State.Open))
+	}
+	if stale := w.Header().Get("X-State-Stale"); stale != "" {
+		t.Errorf("X-State-Stale = %q, want none without a known state", stale)
+	}
+}
+
+func TestCurrentStateServesStaleState(t *testing.T) {
+	var failing atomic.Bool
+	s, clock := newTestServer(t, testConfig(t), StateFetchFunc(func(ctx context.Context) (LabState, error) {
+		if failing.Load() {
+			return LabState{}, errors.New("state api unreachable")
+		}
+		return LabState{Open: Pointer(true)}, nil
+	}))
+	if w := serve(s, "GET", "/v15", nil); w.Header().Get("X-State-Stale") != "" {
+		t.Fatal("fresh state is marked as stale")
+	}
+
+	failing.Store(true)
+	clock.Advance(time.Hour)
+	//the poller refreshes the expired state, which fails
+	if _, err := s.refreshLabState(context.Background()); err == nil {
+		t.Fatal("refreshLabState() succeeded, want the fetch error")
+	}
+	w := serve(s, "GET", "/v15", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if doc := decodeJSON[SpaceAPIv15](t, w); doc.State.Open == nil || !*doc.State.Open {
+		t.Errorf("state.open = %s, want the last known state open", formatState(doc.State.Open))
+	}
+	if stale := w.Header().Get("X-State-Stale"); stale != "true" {
+		t.Errorf("X-State-Stale = %q, want true", stale)
+	}
+}
+
+func TestCurrentStateMessage(t *testing.T) {
+	s, _ := newTestServer(t, testConfig(t), staticState(LabState{Open: Pointer(true), Message: "closing early today"}))
+	type document struct {
+		State map[string]any `json:"state"`
+	}
+	doc := decodeJSON[document](t, serve(s, "GET", "/v15", nil))
+	if message := doc.State["message"]; message != "closing early today" {
+		t.Errorf("state.message = %v, want the message of the state api", message)
+	}
+
+	s, _ = newTestServer(t, testConfig(t), staticState(LabState{Open: Pointer(true)}))
+	doc = decodeJSON[document](t, serve(s, "GET", "/v15", nil))
+	if message, ok := doc.State["message"]; ok {
+		t.Errorf("state.message = %v, want it omitted", message)
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import "context"
+
+// StateFetcher fetches the lab state from the door system of a space
+type StateFetcher interface {
+	Fetch(ctx context.Context) (LabState, error)
+}
+
+// LabState is the state of the lab as reported by a StateFetcher, Open and LastChange are nil if unknown
+type LabState struct {
+	Open          *bool
+	LastChange    *int64
+	Message       string
+	TriggerPerson string // who opened or closed the space, only published if ExposeTriggerPerson is set
+}
+
+// StateFetchFunc adapts a function to a StateFetcher, e.g. to fake the state api in tests
+type StateFetchFunc func(ctx context.Context) (LabState, error)
+
+// Fetch calls f(ctx)
+func (f StateFetchFunc) Fetch(ctx context.Context) (LabState, error) {
+	return f(ctx)
+}
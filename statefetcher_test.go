@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// every door system is plugged in through the same interface
+var (
+	_ StateFetcher = (*HTTPStateFetcher)(nil)
+	_ StateFetcher = (*FileStateFetcher)(nil)
+	_ StateFetcher = StateFetchFunc(nil)
+)
+
+func TestHTTPStateFetcher(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"open","last_changed":1700000000,"last_updated":1700000600,"message":"come in","trigger_person":"mel"}`))
+	}))
+	defer upstream.Close()
+
+	var fetcher StateFetcher = newTestHTTPFetcher(testConfig(t), upstream.URL)
+	state, err := fetcher.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("Fetch() = %v", err)
+	}
+	if !equalState(state.Open, Pointer(true)) || state.LastChange == nil || *state.LastChange != 1700000000 {
+		t.Errorf("Fetch() = %s since %v, want open since 1700000000", formatState(state.Open), state.LastChange)
+	}
+	if state.Message != "come in" || state.TriggerPerson != "mel" {
+		t.Errorf("Fetch() message = %q, trigger person = %q, want both from the state api", state.Message, state.TriggerPerson)
+	}
+}
+
+func TestStateFetcherTriggerPerson(t *testing.T) {
+	fetcher := staticState(LabState{Open: Pointer(true), LastChange: Pointer[int64](1700000000), TriggerPerson: "mel"})
+	type document struct {
+		State struct {
+			TriggerPerson *string `json:"trigger_person"`
+		} `json:"state"`
+	}
+
+	config := testConfig(t)
+	s, _ := newTestServer(t, config, fetcher)
+	if person := decodeJSON[document](t, serve(s, "GET", "/v15", nil)).State.TriggerPerson; person != nil {
+		t.Errorf("trigger_person = %q, want it hidden by default", *person)
+	}
+
+	config.ExposeTriggerPerson = true
+	s, _ = newTestServer(t, config, fetcher)
+	if person := decodeJSON[document](t, serve(s, "GET", "/v15", nil)).State.TriggerPerson; person == nil || *person != "mel" {
+		t.Errorf("trigger_person = %v, want the one reported by the state fetcher", person)
+	}
+}
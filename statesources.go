@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+)
+
+// stateMergeStrategies are the ways the states of multiple state apis can be combined
+var stateMergeStrategies = []string{"first", "or", "and"}
+
+// labStateResult is the outcome of fetching the lab state from a single state api
+type labStateResult struct {
+	state LabState
+	err   error
+}
+
+// HTTPStateFetcher fetches the lab state from the configured state apis serving JSON
+type HTTPStateFetcher struct {
+	config   Config
+	client   *http.Client
+	throttle *throttle // the state apis that asked to back off
+	clock    Clock
+	logger   *slog.Logger
+}
+
+// NewHTTPStateFetcher returns a fetcher for the state apis of config, requests are sent with client
+func NewHTTPStateFetcher(config Config, client *http.Client, clock Clock, logger *slog.Logger) *HTTPStateFetcher {
+	return &HTTPStateFetcher{config: config, client: client, throttle: newThrottle(), clock: clock, logger: logger}
+}
+
+// Fetch fetches the lab state from all configured state apis and combines them with the configured strategy
+func (f *HTTPStateFetcher) Fetch(ctx context.Context) (LabState, error) {
+	urls := f.config.StateAPIURLs
+	if len(urls) == 1 {
+		return f.fetchLabStateFrom(ctx, urls[0])
+	}
+
+	results := make([]labStateResult, len(urls))
+	var wg sync.WaitGroup
+	for i, url := range urls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			state, err := f.fetchLabStateFrom(ctx, url)
+			results[i] = labStateResult{state: state, err: err}
+		}()
+	}
+	wg.Wait()
+	return mergeLabStates(f.config.StateMergeStrategy, results)
+}
+
+// mergeLabStates combines the results of multiple state apis given in order of priority, the message and
+// last change are taken from the source that decided the state:
+//   - first uses the first source that knows whether the space is open
+//   - or reports open if any source does
+//   - and reports open only if every source that knows the state does
+//
+// failed sources are left out, it only fails if all of them failed
+func mergeLabStates(strategy string, results []labStateResult) (LabState, error) {
+	var known []LabState
+	var unknown *LabState
+	var errs []error
+	for _, result := range results {
+		switch {
+		case result.err != nil:
+			errs = append(errs, result.err)
+		case result.state.Open != nil:
+			known = append(known, result.state)
+		case unknown == nil:
+			unknown = &result.state
+		}
+	}
+	if len(known) == 0 {
+		if unknown != nil {
+			return *unknown, nil
+		}
+		return LabState{}, errors.Join(errs...)
+	}
+
+	switch strategy {
+	case "or":
+		for _, state := range known {
+			if *state.Open {
+				return state, nil
+			}
+		}
+	case "and":
+		for _, state := range known {
+			if !*state.Open {
+				return state, nil
+			}
+		}
+	}
+	return known[0], nil
+}
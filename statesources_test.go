@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestHTTPFetcher returns a fetcher for the state apis at urls that retries without noticeable delays
+func newTestHTTPFetcher(config Config, urls ...string) *HTTPStateFetcher {
+	config.StateAPIURLs = urls
+	config.StateFetchRetryDelay = time.Millisecond
+	config.StateFetchRetryJitter = 0
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewHTTPStateFetcher(config, http.DefaultClient, newFakeClock(testNow), logger)
+}
+
+// slowHandler answers after delay, or once the client went away
+func slowHandler(delay time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+			w.Write([]byte(`{"status":"open"}`))
+		case <-r.Context().Done():
+		}
+	}
+}
+
+func TestHTTPStateFetcherTimeout(t *testing.T) {
+	upstream := httptest.NewServer(slowHandler(5 * time.Second))
+	defer upstream.Close()
+
+	config := testConfig(t)
+	config.StateFetchTimeout = 50 * time.Millisecond
+	config.StateFetchAttempts = 1
+	fetcher := newTestHTTPFetcher(config, upstream.URL)
+
+	start := time.Now()
+	_, err := fetcher.Fetch(context.Background())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Fetch() = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Fetch() took %s, want it to give up after the timeout", elapsed)
+	}
+}
+
+func TestSpaceAPIv15UpstreamTimeout(t *testing.T) {
+	upstream := httptest.NewServer(slowHandler(5 * time.Second))
+	defer upstream.Close()
+
+	config := testConfig(t)
+	config.StateFetchTimeout = 50 * time.Millisecond
+	config.StateFetchAttempts = 1
+	s, _ := newTestServer(t, config, newTestHTTPFetcher(config, upstream.URL))
+
+	start := time.Now()
+	w := serve(s, "GET", "/v15", nil)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("request took %s, want it to return after the state api timed out", elapsed)
+	}
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if doc := decodeJSON[SpaceAPIv15](t, w); doc.State.Open != nil {
+		t.Errorf("state.open = %s, want unknown", formatState(doc.State.Open))
+	}
+}
+
+func TestHTTPStateFetcherStatusError(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "database on fire", http.StatusInternalServerError)
+	}))
+	defer upstream.Close()
+
+	_, err := newTestHTTPFetcher(testConfig(t), upstream.URL).Fetch(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("Fetch() = %v, want an error mentioning status 500", err)
+	}
+}
+
+func TestHTTPStateFetcherRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		failure  int
+		want     *bool
+		requests int
+	}{
+		{"server errors are retried", http.StatusBadGateway, Pointer(true), 3},
+		{"client errors are not retried", http.StatusNotFound, nil, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests atomic.Int32
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if requests.Add(1) <= 2 {
+					http.Error(w, http.StatusText(test.failure), test.failure)
+					return
+				}
+				w.Write([]byte(`{"status":"open"}`))
+			}))
+			defer upstream.Close()
+
+			config := testConfig(t)
+			config.StateFetchAttempts = 3
+			state, err := newTestHTTPFetcher(config, upstream.URL).Fetch(context.Background())
+			if test.want != nil && (err != nil || !equalState(state.Open, test.want)) {
+				t.Errorf("Fetch() = %s, %v, want %s", formatState(state.Open), err, formatState(test.want))
+			}
+			if test.want == nil && err == nil {
+				t.Errorf("Fetch() = %s, want an error", formatState(state.Open))
+			}
+			if got := requests.Load(); got != int32(test.requests) {
+				t.Errorf("state api was requested %d times, want %d", got, test.requests)
+			}
+		})
+	}
+}
+
+func TestHTTPStateFetcherCancel(t *testing.T) {
+	upstream := httptest.NewServer(slowHandler(5 * time.Second))
+	defer upstream.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := newTestHTTPFetcher(testConfig(t), upstream.URL).Fetch(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Fetch() = %v, want a context error", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Fetch() took %s, want it to return once the context is cancelled", elapsed)
+	}
+}
+
+func TestMergeLabStates(t *testing.T) {
+	open := labStateResult{state: LabState{Open: Pointer(true), Message: "door"}}
+	closed := labStateResult{state: LabState{Open: Pointer(false), Message: "switch"}}
+	unknown := labStateResult{state: LabState{Message: "unknown"}}
+	failed := labStateResult{err: errors.New("state api unreachable")}
+	tests := []struct {
+		strategy string
+		results  []labStateResult
+		want     *bool
+		message  string
+	}{
+		{"first", []labStateResult{open, closed}, Pointer(true), "door"},
+		{"first", []labStateResult{closed, open}, Pointer(false), "switch"},
+		{"first", []labStateResult{failed, unknown, closed}, Pointer(false), "switch"},
+		{"or", []labStateResult{closed, open}, Pointer(true), "door"},
+		{"or", []labStateResult{closed, closed}, Pointer(false), "switch"},
+		{"or", []labStateResult{failed, open}, Pointer(true), "door"},
+		{"and", []labStateResult{open, closed}, Pointer(false), "switch"},
+		{"and", []labStateResult{open, open}, Pointer(true), "door"},
+		{"and", []labStateResult{open, failed}, Pointer(true), "door"},
+		{"and", []labStateResult{open, unknown}, Pointer(true), "door"},
+		{"or", []labStateResult{failed, unknown}, nil, "unknown"},
+	}
+	for _, test := range tests {
+		state, err := mergeLabStates(test.strategy, test.results)
+		if err != nil {
+			t.Errorf("%s of %v: mergeLabStates() = %v", test.strategy, test.results, err)
+			continue
+		}
+		if !equalState(state.Open, test.want) || state.Message != test.message {
+			t.Errorf("%s of %v: mergeLabStates() = %s (%s), want %s (%s)", test.strategy, test.results,
+				formatState(state.Open), state.Message, formatState(test.want), test.message)
+		}
+	}
+
+	for _, strategy := range stateMergeStrategies {
+		if _, err := mergeLabStates(strategy, []labStateResult{failed, failed}); err == nil {
+			t.Errorf("%s of failed sources: mergeLabStates() succeeded, want an error", strategy)
+		}
+	}
+}
+
+func TestHTTPStateFetcherMultipleSources(t *testing.T) {
+	status := func(body string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		}))
+	}
+	door := status(`{"status":"closed"}`)
+	defer door.Close()
+	presence := status(`{"status":"open"}`)
+	defer presence.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	}))
+	defer broken.Close()
+
+	tests := []struct {
+		strategy string
+		want     bool
+	}{
+		{"first", false},
+		{"or", true},
+		{"and", false},
+	}
+	for _, test := range tests {
+		config := testConfig(t)
+		config.StateMergeStrategy = test.strategy
+		state, err := newTestHTTPFetcher(config, broken.URL, door.URL, presence.URL).Fetch(context.Background())
+		if err != nil || !equalState(state.Open, Pointer(test.want)) {
+			t.Errorf("%s: Fetch() = %s, %v, want %v", test.strategy, formatState(state.Open), err, test.want)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Time
+		ok    bool
+	}{
+		{"120", testNow.Add(2 * time.Minute), true},
+		{" 0 ", testNow, true},
+		{"Fri, 01 Mar 2024 18:05:00 GMT", testNow.Add(5 * time.Minute), true},
+		{"Friday, 01-Mar-24 18:05:00 GMT", testNow.Add(5 * time.Minute), true},
+		{"86400", testNow.Add(maxRetryAfter), true},
+		{"Sat, 02 Mar 2024 18:00:00 GMT", testNow.Add(maxRetryAfter), true},
+		{"", time.Time{}, false},
+		{"-5", time.Time{}, false},
+		{"soon", time.Time{}, false},
+	}
+	for _, test := range tests {
+		until, ok := parseRetryAfter(test.value, testNow)
+		if ok != test.ok || !until.Equal(test.want) {
+			t.Errorf("parseRetryAfter(%q) = %s, %v, want %s, %v", test.value, until, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestHTTPStateFetcherRetryAfter(t *testing.T) {
+	tests := []struct {
+		name       string
+		retryAfter string
+	}{
+		{"seconds", "60"},
+		{"http date", "Fri, 01 Mar 2024 18:01:00 GMT"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests atomic.Int32
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if requests.Add(1) == 1 {
+					w.Header().Set("Retry-After", test.retryAfter)
+					http.Error(w, "slow down", http.StatusTooManyRequests)
+					return
+				}
+				w.Write([]byte(`{"status":"open"}`))
+			}))
+			defer upstream.Close()
+
+			config := testConfig(t)
+			config.StateFetchAttempts = 3
+			fetcher := newTestHTTPFetcher(config, upstream.URL)
+			clock := newFakeClock(testNow)
+			fetcher.clock = clock
+
+			var statusErr *statusError
+			if _, err := fetcher.Fetch(context.Background()); !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusTooManyRequests {
+				t.Fatalf("Fetch() = %v, want status 429", err)
+			}
+			clock.Advance(30 * time.Second)
+			var throttledErr *throttledError
+			if _, err := fetcher.Fetch(context.Background()); !errors.As(err, &throttledErr) || !throttledErr.Until.Equal(testNow.Add(time.Minute)) {
+				t.Errorf("Fetch() while backing off = %v, want it throttled until %s", err, testNow.Add(time.Minute))
+			}
+			if got := requests.Load(); got != 1 {
+				t.Errorf("state api was requested %d times while backing off, want 1", got)
+			}
+
+			clock.Advance(30 * time.Second)
+			if state, err := fetcher.Fetch(context.Background()); err != nil || !equalState(state.Open, Pointer(true)) {
+				t.Errorf("Fetch() after backing off = %s, %v, want open", formatState(state.Open), err)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// StateEvent is pushed to streaming clients whenever the open state changes
+type StateEvent struct {
+	Open       *bool `json:"open"`
+	LastChange int64 `json:"lastchange,omitempty"`
+}
+
+// stateBroadcaster fans out state changes to all subscribed clients
+type stateBroadcaster struct {
+	mu          sync.Mutex
+	subscribers map[chan StateEvent]struct{}
+	closed      bool
+	logger      *slog.Logger
+}
+
+func newStateBroadcaster(logger *slog.Logger) *stateBroadcaster {
+	return &stateBroadcaster{subscribers: make(map[chan StateEvent]struct{}), logger: logger}
+}
+
+// subscribe registers a new client, the returned channel receives every subsequent state change
+// and is closed once the server shuts down
+func (b *stateBroadcaster) subscribe() chan StateEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan StateEvent, 4)
+	if b.closed {
+		close(ch)
+		return ch
+	}
+	b.subscribers[ch] = struct{}{}
+	return ch
+}
+
+// close ends all subscriptions by closing their channels, the server is shutting down
+func (b *stateBroadcaster) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for ch := range b.subscribers {
+		close(ch)
+		delete(b.subscribers, ch)
+	}
+}
+
+// unsubscribe removes a client, it must be called once the client is gone
+func (b *stateBroadcaster) unsubscribe(ch chan StateEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.subscribers, ch)
+}
+
+// publish sends the event to all clients, clients that can't keep up miss the event instead of blocking the others
+func (b *stateBroadcaster) publish(event StateEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			b.logger.Warn("dropping state event for slow client")
+		}
+	}
+}
+
+// newStateEvent builds a state event from a fetched lab state
+func newStateEvent(open *bool, lastChange *int64) StateEvent {
+	event := StateEvent{Open: open}
+	if lastChange != nil {
+		event.LastChange = *lastChange
+	}
+	return event
+}
+
+// currentStateEvent returns the state event of the effective state, which is the fetched lab state unless a manual
+// override takes precedence, ok is false if neither is known
+func (s *Server) currentStateEvent() (event StateEvent, ok bool) {
+	if open, _, since, overridden := s.stateOverride.get(); overridden {
+		return StateEvent{Open: Pointer(open), LastChange: since.Unix()}, true
+	}
+	state, ok := s.stateCache.last()
+	return newStateEvent(state.Open, state.LastChange), ok
+}
+
+// runStatePoller refreshes the lab state every interval (plus the configured jitter) so that changes are detected
+// without any requests, it returns once ctx is cancelled
+func (s *Server) runStatePoller(ctx context.Context, interval time.Duration) {
+	for {
+		s.refreshLabState(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval + s.jitter()):
+		}
+	}
+}
+
+// handleStateEvents streams state changes to the client as server-sent events
+func (s *Server) handleStateEvents(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	ch := s.stateChanges.subscribe()
+	defer s.stateChanges.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	//keep reverse proxies like nginx from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	//start with the current state so clients don't have to wait for the next change
+	event, _ := s.currentStateEvent()
+	if err := writeStateEvent(w, event); err != nil {
+		return
+	}
+	rc.Flush()
+
+	heartbeat := time.NewTicker(s.config.StreamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-ch:
+			if !ok {
+				fmt.Fprint(w, "event: closing\ndata: {\"reason\":\"server closing\"}\n\n")
+				rc.Flush()
+				return
+			}
+			if err := writeStateEvent(w, event); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			//comments are ignored by clients but keep idle connections from being closed
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeStateEvent writes a single server-sent event
+func writeStateEvent(w http.ResponseWriter, event StateEvent) error {
+	p, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: state\ndata: %s\n\n", p)
+	return err
+}
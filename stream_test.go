@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+)
+
+// readEvent reads the next server-sent event from r, skipping heartbeats
+func readEvent(r *bufio.Reader) (string, error) {
+	var event strings.Builder
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return event.String(), err
+		}
+		if line == "\n" {
+			if event.Len() > 0 {
+				return event.String(), nil
+			}
+			continue
+		}
+		if !strings.HasPrefix(line, ":") {
+			event.WriteString(line)
+		}
+	}
+}
+
+func TestStateEventsClosing(t *testing.T) {
+	s, _ := newTestServer(t, testConfig(t), staticState(LabState{Open: Pointer(true), LastChange: Pointer[int64](1700000000)}))
+	s.refreshLabState(context.Background())
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/events/state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", contentType)
+	}
+	body := bufio.NewReader(resp.Body)
+	if event, err := readEvent(body); err != nil || event != "event: state\ndata: {\"open\":true,\"lastchange\":1700000000}\n" {
+		t.Fatalf("initial event = %q, %v, want the current state", event, err)
+	}
+
+	s.stateChanges.close()
+	if event, err := readEvent(body); err != nil || !strings.HasPrefix(event, "event: closing\n") {
+		t.Errorf("event after closing = %q, %v, want a closing event", event, err)
+	}
+	if event, err := readEvent(body); !errors.Is(err, io.EOF) {
+		t.Errorf("stream continued with %q, %v, want it closed", event, err)
+	}
+}
+
+func TestRunClosesStreams(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "spaceapi.sock")
+	config := testConfig(t)
+	config.ListenAddr = "unix:" + socket
+	config.ShutdownTimeout = 5 * time.Second
+	s, _ := newTestServer(t, config, staticState(LabState{Open: Pointer(true)}))
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+	}
+	client := &http.Client{Transport: &http.Transport{DialContext: dial}}
+	var resp *http.Response
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		var err error
+		if resp, err = client.Get("http://spaceapi/events/state"); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server didn't start: %v", err)
+		}
+	}
+	defer resp.Body.Close()
+	events := bufio.NewReader(resp.Body)
+	if _, err := readEvent(events); err != nil {
+		t.Fatal(err)
+	}
+
+	wsCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, _, err := websocket.Dial(wsCtx, "ws://spaceapi/ws/state", &websocket.DialOptions{HTTPClient: client})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.CloseNow()
+	var initial StateEvent
+	if err := wsjson.Read(wsCtx, c, &initial); err != nil {
+		t.Fatal(err)
+	}
+
+	stop()
+	if event, err := readEvent(events); err != nil || !strings.HasPrefix(event, "event: closing\n") {
+		t.Errorf("event on shutdown = %q, %v, want a closing event", event, err)
+	}
+	if _, err := readEvent(events); !errors.Is(err, io.EOF) {
+		t.Errorf("event stream = %v after shutdown, want it closed", err)
+	}
+	if _, _, err := c.Read(wsCtx); websocket.CloseStatus(err) != websocket.StatusGoingAway {
+		t.Errorf("websocket read on shutdown = %v, want it closed as going away", err)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run() = %v, want a clean shutdown", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() didn't return after shutdown")
+	}
+}
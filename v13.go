@@ -0,0 +1,172 @@
+package main
+
+import (
+	"net/http"
+)
+
+// SpaceAPIv13 represents the SpaceAPI v0.13 structure, which predates the v14/v15 layout
+type SpaceAPIv13 struct {
+	API                 string         `json:"api"`   // Required
+	Space               string         `json:"space"` // Required
+	Logo                string         `json:"logo"`  // Required
+	URL                 string         `json:"url"`   // Required
+	Location            *LocationV13   `json:"location"`
+	SpaceFed            *SpaceFedV13   `json:"spacefed,omitempty"`
+	Cam                 []string       `json:"cam,omitempty"`
+	State               *StateV13      `json:"state"` // Required
+	Events              []Event        `json:"events,omitempty"`
+	Contact             *ContactV13    `json:"contact"`               // Required
+	IssueReportChannels []string       `json:"issue_report_channels"` // Required
+	Sensors             *Sensors       `json:"sensors,omitempty"`
+	Feeds               *Feeds         `json:"feeds,omitempty"`
+	Cache               *Cache         `json:"cache,omitempty"`
+	Projects            []string       `json:"projects,omitempty"`
+	RadioShow           []RadioShowV13 `json:"radio_show,omitempty"`
+}
+
+// LocationV13 represents the location of the space in v13
+type LocationV13 struct {
+	Address string  `json:"address,omitempty"`
+	Lat     float64 `json:"lat"` // Required
+	Lon     float64 `json:"lon"` // Required
+}
+
+// SpaceFedV13 represents SpaceFED information in v13
+type SpaceFedV13 struct {
+	SpaceNet   bool `json:"spacenet"`   // Required
+	SpaceSAML  bool `json:"spacesaml"`  // Required
+	SpacePhone bool `json:"spacephone"` // Required
+}
+
+// StateV13 represents the state of the space in v13, where open is required but may be null
+type StateV13 struct {
+	Open          *bool      `json:"open"` // Required
+	LastChange    int64      `json:"lastchange,omitempty"`
+	TriggerPerson string     `json:"trigger_person,omitempty"`
+	Message       string     `json:"message,omitempty"`
+	Icon          *StateIcon `json:"icon,omitempty"`
+}
+
+// ContactV13 contains the contact methods known to v13
+type ContactV13 struct {
+	Phone      string      `json:"phone,omitempty"`
+	SIP        string      `json:"sip,omitempty"`
+	Keymasters []Keymaster `json:"keymasters,omitempty"`
+	IRC        string      `json:"irc,omitempty"`
+	Twitter    string      `json:"twitter,omitempty"`
+	Facebook   string      `json:"facebook,omitempty"`
+	Identica   string      `json:"identica,omitempty"`
+	Foursquare string      `json:"foursquare,omitempty"`
+	Email      string      `json:"email,omitempty"`
+	ML         string      `json:"ml,omitempty"`
+	Jabber     string      `json:"jabber,omitempty"`
+	IssueMail  string      `json:"issue_mail,omitempty"`
+}
+
+// RadioShowV13 represents a radio show in v13
+type RadioShowV13 struct {
+	Name  string `json:"name"`  // Required
+	URL   string `json:"url"`   // Required
+	Type  string `json:"type"`  // Required
+	Start string `json:"start"` // Required
+	End   string `json:"end"`   // Required
+}
+
+func (s *Server) handleSpaceApiV13(w http.ResponseWriter, r *http.Request) {
+	s.setCacheControl(w)
+	//the document includes sensors, events and projects, which change independently of the last state change,
+	//so Last-Modified is only informational and the document is revalidated by its ETag
+	doc := s.currentSpaceApiDocument(w, r)
+	setLastModified(w, doc.State.LastChange)
+	//the fields are selected on the v15 document, so the fields v13 requires are always present
+	s.filterFields(r, doc)
+	s.writeJSON(w, r, toSpaceAPIv13(doc))
+}
+
+// toSpaceAPIv13 maps a v15 document into the v13 layout
+func toSpaceAPIv13(doc *SpaceAPIv15) *SpaceAPIv13 {
+	v13 := &SpaceAPIv13{
+		API:      "0.13",
+		Space:    doc.Space,
+		Logo:     doc.Logo,
+		URL:      doc.URL,
+		Location: &LocationV13{},
+		Cam:      doc.Cam,
+		State:    &StateV13{},
+		Events:   doc.Events,
+		Contact:  &ContactV13{},
+		Sensors:  doc.Sensors,
+		Feeds:    doc.Feeds,
+		Cache:    doc.Cache,
+		Projects: doc.Projects,
+	}
+
+	if doc.Location != nil {
+		v13.Location = &LocationV13{
+			Address: doc.Location.Address,
+			Lat:     doc.Location.Lat,
+			Lon:     doc.Location.Lon,
+		}
+	}
+	if doc.SpaceFed != nil {
+		v13.SpaceFed = &SpaceFedV13{
+			SpaceNet:  doc.SpaceFed.SpaceNet,
+			SpaceSAML: doc.SpaceFed.SpaceSAML,
+		}
+	}
+	if doc.State != nil {
+		v13.State = &StateV13{
+			Open:          doc.State.Open,
+			LastChange:    doc.State.LastChange,
+			TriggerPerson: doc.State.TriggerPerson,
+			Message:       doc.State.Message,
+			Icon:          doc.State.Icon,
+		}
+	}
+	if doc.Contact != nil {
+		c := doc.Contact
+		v13.Contact = &ContactV13{
+			Phone:      c.Phone,
+			SIP:        c.SIP,
+			Keymasters: c.Keymasters,
+			IRC:        c.IRC,
+			Twitter:    c.Twitter,
+			Facebook:   c.Facebook,
+			Identica:   c.Identica,
+			Foursquare: c.Foursquare,
+			Email:      c.Email,
+			ML:         c.ML,
+			Jabber:     c.XMPP,
+			IssueMail:  c.IssueMail,
+		}
+	}
+	if doc.RadioShow != nil {
+		v13.RadioShow = []RadioShowV13{{
+			Name:  doc.RadioShow.Name,
+			URL:   doc.RadioShow.URL,
+			Type:  doc.RadioShow.Type,
+			Start: doc.RadioShow.StartTime,
+			End:   doc.RadioShow.EndTime,
+		}}
+	}
+	v13.IssueReportChannels = issueReportChannels(v13.Contact)
+	return v13
+}
+
+// issueReportChannels lists the contact fields that can be used to report issues, as required by v13
+func issueReportChannels(c *ContactV13) []string {
+	channels := []string{}
+	if c.IssueMail != "" {
+		channels = append(channels, "issue_mail")
+	}
+	if c.Email != "" {
+		channels = append(channels, "email")
+	}
+	if c.ML != "" {
+		channels = append(channels, "ml")
+	}
+	if c.Twitter != "" {
+		channels = append(channels, "twitter")
+	}
+	return channels
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+)
+
+// version, commit and buildTime are set at build time, e.g. go build -ldflags "-X main.version=1.2.3 -X main.commit=abc123 -X main.buildTime=2024-01-01T00:00:00Z"
+var (
+	version   = ""
+	commit    = ""
+	buildTime = ""
+)
+
+// VersionInfo describes the running build
+type VersionInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildTime string `json:"build_time,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+// buildVersion returns the build info set via ldflags, falling back to the info embedded by the go toolchain
+func buildVersion() VersionInfo {
+	info := VersionInfo{Version: version, Commit: commit, BuildTime: buildTime, GoVersion: runtime.Version()}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" {
+			info.Version = build.Main.Version
+		}
+		for _, setting := range build.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = setting.Value
+			case setting.Key == "vcs.time" && info.BuildTime == "":
+				info.BuildTime = setting.Value
+			}
+		}
+	}
+	if info.Version == "" {
+		info.Version = "(devel)"
+	}
+	return info
+}
+
+// handleVersion reports which build is running
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	s.writeJSON(w, r, buildVersion())
+}
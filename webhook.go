@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// WebhookPayload is the body posted to the webhooks on a state change
+type WebhookPayload struct {
+	Open      bool  `json:"open"`
+	Timestamp int64 `json:"timestamp"`
+}
+
+// sendWebhooks notifies all configured webhooks about a state change concurrently in background workers,
+// a failing webhook is logged and doesn't hold up the others
+func (s *Server) sendWebhooks(transition stateTransition) {
+	if transition.Open == nil {
+		return
+	}
+	ctx := s.workersCtx
+	payload, err := json.Marshal(WebhookPayload{Open: *transition.Open, Timestamp: transition.At.Unix()})
+	if err != nil {
+		s.logger.Error("error while marshalling webhook payload", "error", err)
+		return
+	}
+
+	for _, url := range s.config.WebhookURLs {
+		started := s.startWorker(func() {
+			if err := s.sendWebhook(ctx, url, payload); err != nil {
+				s.logger.Error("webhook failed", "url", url, "attempts", s.config.WebhookAttempts, "error", err)
+			}
+		})
+		//no new workers are started once Run is waiting for them to stop
+		if !started {
+			s.logger.Warn("shutting down, not sending webhooks", "open", *transition.Open)
+			return
+		}
+	}
+}
+
+// sendWebhook posts the payload to url, retrying failed requests with exponential backoff until ctx is cancelled
+func (s *Server) sendWebhook(ctx context.Context, url string, payload []byte) error {
+	var lastErr error
+	for attempt := 1; attempt <= s.config.WebhookAttempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-time.After(retryDelay(attempt-1, time.Second, 500*time.Millisecond)):
+			case <-ctx.Done():
+				return fmt.Errorf("%w (last error: %v)", ctx.Err(), lastErr)
+			}
+		}
+
+		lastErr = s.postWebhook(ctx, url, payload)
+		if lastErr == nil {
+			s.logger.Debug("webhook sent", "url", url, "attempt", attempt)
+			return nil
+		}
+		s.logger.Warn("webhook request failed", "url", url, "attempt", attempt, "error", lastErr)
+	}
+	return lastErr
+}
+
+// postWebhook performs a single webhook request bounded by the webhook timeout
+func (s *Server) postWebhook(ctx context.Context, url string, payload []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, s.config.WebhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned status %d (%s)", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	return nil
+}
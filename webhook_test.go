@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSendWebhookStopsOnShutdown(t *testing.T) {
+	var requests atomic.Int32
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer hook.Close()
+
+	config := testConfig(t)
+	config.WebhookAttempts = 5
+	s, _ := newTestServer(t, config, staticState(LabState{}))
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	err := s.sendWebhook(ctx, hook.URL, []byte(`{"open":true}`))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("sendWebhook() = %v, want it cancelled", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("sendWebhook() took %s, want it to stop retrying on shutdown", elapsed)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("webhook was requested %d times, want once before the shutdown", got)
+	}
+}
+
+func TestSendWebhooksAfterShutdown(t *testing.T) {
+	var requests atomic.Int32
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer hook.Close()
+
+	config := testConfig(t)
+	config.WebhookURLs = []string{hook.URL}
+	s, _ := newTestServer(t, config, staticState(LabState{}))
+	s.stopping = true
+
+	s.sendWebhooks(stateTransition{Previous: Pointer(false), Open: Pointer(true), At: testNow})
+	s.workers.Wait()
+	if got := requests.Load(); got != 0 {
+		t.Errorf("webhook was requested %d times while shutting down, want none", got)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+)
+
+// handleStateWebSocket sends the current state on connect and every subsequent state change over a websocket
+func (s *Server) handleStateWebSocket(w http.ResponseWriter, r *http.Request) {
+	//registered before the connection is hijacked, so shutdown can't miss it
+	s.streams.Add(1)
+	defer s.streams.Done()
+
+	c, err := websocket.Accept(w, r, &websocket.AcceptOptions{OriginPatterns: s.websocketOriginPatterns()})
+	if err != nil {
+		s.logger.Warn("error while accepting websocket", "remote_addr", r.RemoteAddr, "error", err)
+		return
+	}
+	defer c.CloseNow()
+
+	ch := s.stateChanges.subscribe()
+	defer s.stateChanges.unsubscribe(ch)
+
+	//clients are not expected to send anything, this also handles pongs and the closing handshake
+	ctx := c.CloseRead(r.Context())
+
+	//all writes happen on this goroutine, so they never interleave
+	event, _ := s.currentStateEvent()
+	if err := s.writeStateMessage(ctx, c, event); err != nil {
+		return
+	}
+
+	ping := time.NewTicker(s.config.StreamHeartbeat)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-ch:
+			if !ok {
+				c.Close(websocket.StatusGoingAway, "server closing")
+				return
+			}
+			if err := s.writeStateMessage(ctx, c, event); err != nil {
+				return
+			}
+		case <-ping.C:
+			pingCtx, cancel := context.WithTimeout(ctx, s.config.StreamHeartbeat)
+			err := c.Ping(pingCtx)
+			cancel()
+			if err != nil {
+				s.logger.Debug("reaping dead websocket", "remote_addr", r.RemoteAddr, "error", err)
+				return
+			}
+		}
+	}
+}
+
+// writeStateMessage sends a state event as JSON message, a client that doesn't accept it in time is considered dead
+func (s *Server) writeStateMessage(ctx context.Context, c *websocket.Conn, event StateEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, s.config.StreamHeartbeat)
+	defer cancel()
+	return wsjson.Write(ctx, c, event)
+}
+
+// websocketOriginPatterns translates the allowed CORS origins into host patterns for the websocket origin check
+func (s *Server) websocketOriginPatterns() []string {
+	if len(s.config.AllowedOrigins) == 0 || slices.Contains(s.config.AllowedOrigins, "*") {
+		return []string{"*"}
+	}
+
+	var patterns []string
+	for _, origin := range s.config.AllowedOrigins {
+		if u, err := url.Parse(origin); err == nil && u.Host != "" {
+			patterns = append(patterns, u.Host)
+		}
+	}
+	return patterns
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+)
+
+func TestStateWebSocket(t *testing.T) {
+	var open atomic.Bool
+	open.Store(true)
+	s, _ := newTestServer(t, testConfig(t), StateFetchFunc(func(ctx context.Context) (LabState, error) {
+		return LabState{Open: Pointer(open.Load()), LastChange: Pointer[int64](1700000000)}, nil
+	}))
+	s.refreshLabState(context.Background())
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws/state", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.CloseNow()
+
+	var initial map[string]json.RawMessage
+	if err := wsjson.Read(ctx, c, &initial); err != nil {
+		t.Fatal(err)
+	}
+	if string(initial["open"]) != "true" || string(initial["lastchange"]) != "1700000000" || len(initial) != 2 {
+		t.Errorf("initial message = %v, want open and lastchange of the current state", initial)
+	}
+
+	open.Store(false)
+	s.refreshLabState(context.Background())
+	var event StateEvent
+	if err := wsjson.Read(ctx, c, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Open == nil || *event.Open {
+		t.Errorf("event = %+v, want the change to closed", event)
+	}
+}